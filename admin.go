@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// withAdminAuth requires basic auth with $ADMIN_USER and $ADMIN_PASSWORD.
+// Without $ADMIN_PASSWORD the admin pages are open.
+func withAdminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="paper admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func isAdmin(r *http.Request) bool {
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(os.Getenv("ADMIN_USER"))) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
+}
+
+type adminDisplay struct {
+	Display    Display
+	Snapshot   calendarSnapshot
+	Fetched    bool
+	Telemetry  Telemetry
+	Polled     bool
+	LowBattery bool
+}
+
+var adminTemplate = template.Must(template.New("admin").Funcs(template.FuncMap{
+	"ago": func(t time.Time) string {
+		return time.Since(t).Truncate(time.Second).String() + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>paper displays</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.display { display: flex; gap: 2em; margin-bottom: 2em; border-bottom: 1px solid #ccc; padding-bottom: 2em; }
+.display img { border: 1px solid #888; max-width: 640px; }
+.error { color: #c00; }
+td { padding-right: 1em; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Displays</h1>
+{{range .}}
+<div class="display">
+	<img src="/admin/preview?display={{.Display.ID}}" alt="{{.Display.Name}}">
+	<div>
+	<h2>{{.Display.Name}} <small>{{.Display.ID}}</small></h2>
+	<table>
+	<tr><td>Last poll</td><td>{{if .Polled}}{{ago .Telemetry.LastPoll}} from {{.Telemetry.RemoteAddr}}{{else}}never{{end}}</td></tr>
+	<tr><td>Device</td><td>{{.Telemetry.Device}} {{.Telemetry.Firmware}}</td></tr>
+	<tr><td>Battery</td><td{{if .LowBattery}} class="error"{{end}}>{{if .Telemetry.BatteryMV}}{{.Telemetry.BatteryMV}} mV{{end}}</td></tr>
+	<tr><td>RSSI</td><td>{{if .Telemetry.RSSI}}{{.Telemetry.RSSI}} dBm{{end}}</td></tr>
+	<tr><td>Calendar</td><td>{{if .Fetched}}{{len .Snapshot.Events}} events, fetched {{ago .Snapshot.Fetched}}{{else}}never fetched{{end}}</td></tr>
+	{{if .Snapshot.Err}}<tr><td>Last error</td><td class="error">{{ago .Snapshot.Attempted}}: {{.Snapshot.Err}}</td></tr>{{end}}
+	</table>
+	<form method="post" action="/admin/refresh?display={{.Display.ID}}"><button>Refresh calendar</button></form>
+	</div>
+</div>
+{{else}}
+<p>No displays configured.</p>
+{{end}}
+</body>
+</html>
+`))
+
+func serveAdmin(w http.ResponseWriter, r *http.Request) {
+	var list []adminDisplay
+	for _, d := range displays.all() {
+		a := adminDisplay{Display: d}
+		a.Snapshot, _ = calendars.get(d.ID)
+		a.Fetched = !a.Snapshot.Fetched.IsZero()
+		a.Telemetry, a.Polled = devices.get(d.ID)
+		a.LowBattery = a.Telemetry.lowBattery(d)
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Display.ID < list[j].Display.ID
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := adminTemplate.Execute(w, list); err != nil {
+		log.Println(err)
+	}
+}
+
+// serveAdminPreview renders a display as PNG without counting it as a poll,
+// at ?at= if given.
+func serveAdminPreview(w http.ResponseWriter, r *http.Request) {
+	d, ok := displays.get(sanitize(r.URL.Query().Get("display")))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	c, err := requestClock(r)
+	if err == errAtNotAllowed {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	schedule, err := displaySchedule(d, c)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	if t, ok := devices.get(d.ID); ok {
+		schedule.LowBattery = t.lowBattery(d)
+	}
+	profile := d.profile()
+	colors := profile.colors(d.Colors.withDefaults())
+	opts := renderOptions{Format: "png", Dither: "fs"}
+	_, render := renderDisplay(d, c, schedule, 1, profile, colors)
+	w.Header().Set("Content-Type", opts.contentType())
+	if err := opts.encode(w, render(), profile, colors); err != nil {
+		log.Println(err)
+	}
+}
+
+// serveAdminRefresh fetches the calendar of a display now.
+func serveAdminRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d, ok := displays.get(sanitize(r.URL.Query().Get("display")))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	calendars.refresh(d)
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
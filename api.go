@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type apiMeeting struct {
+	Summary   string    `json:"summary"`
+	Organizer string    `json:"organizer,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+type apiSlot struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Blocked bool      `json:"blocked"`
+	Pattern string    `json:"pattern,omitempty"`
+}
+
+// apiSchedule is the JSON form of a Schedule. FreeUntil is only set while the
+// room is free and a meeting follows, BusyUntil only while it is blocked.
+type apiSchedule struct {
+	Display    string      `json:"display"`
+	Name       string      `json:"name"`
+	Blocked    bool        `json:"blocked"`
+	FreeUntil  *time.Time  `json:"freeUntil,omitempty"`
+	BusyUntil  *time.Time  `json:"busyUntil,omitempty"`
+	Current    *apiMeeting `json:"current,omitempty"`
+	Next       *apiMeeting `json:"next,omitempty"`
+	NextChange *time.Time  `json:"nextChange,omitempty"`
+	StaleSince string      `json:"staleSince,omitempty"`
+	Slots      []apiSlot   `json:"slots"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func newAPIMeeting(m *Meeting) *apiMeeting {
+	if m == nil {
+		return nil
+	}
+	return &apiMeeting{m.Summary, m.Organizer, m.Start, m.End}
+}
+
+func newAPISchedule(id string, schedule Schedule) apiSchedule {
+	s := apiSchedule{
+		Display:    id,
+		Name:       schedule.Name,
+		Blocked:    schedule.Blocked,
+		FreeUntil:  optionalTime(schedule.FreeUntil),
+		BusyUntil:  optionalTime(schedule.BusyUntil),
+		Current:    newAPIMeeting(schedule.Current),
+		Next:       newAPIMeeting(schedule.Next),
+		NextChange: optionalTime(schedule.NextChange),
+		StaleSince: schedule.StaleSince,
+		Slots:      []apiSlot{},
+	}
+	for i, info := range schedule.BlockInfos {
+		for j, blocked := range info.Blocked {
+			start := schedule.Start.Add(time.Duration(i)*time.Hour + time.Duration(j)*schedule.SlotLength)
+			slot := apiSlot{Start: start, End: start.Add(schedule.SlotLength), Blocked: blocked}
+			if blocked {
+				slot.Pattern = info.Patterns[j]
+			}
+			s.Slots = append(s.Slots, slot)
+		}
+	}
+	return s
+}
+
+// serveAPI handles /api/displays/{id}/schedule.
+func serveAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/displays/"), "/")
+	if len(parts) != 2 || parts[1] != "schedule" {
+		http.NotFound(w, r)
+		return
+	}
+	d, ok := displays.get(sanitize(parts[0]))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	schedule, err := displaySchedule(d, systemClock{})
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newAPISchedule(d.ID, schedule)); err != nil {
+		log.Println(err)
+	}
+}
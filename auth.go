@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Secret is a credential given literally ("s3cret"), read from a file
+// ({"file": "/run/secrets/token"}) or from the environment ({"env":
+// "ROOM_TOKEN"}). Files and variables are read on every use, so rotated
+// secrets are picked up. It never prints its value.
+type Secret struct {
+	Value string `json:"-"`
+	File  string `json:"file,omitempty"`
+	Env   string `json:"env,omitempty"`
+}
+
+func (s *Secret) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &s.Value); err == nil {
+		return nil
+	}
+	var ref struct {
+		File string `json:"file"`
+		Env  string `json:"env"`
+	}
+	if err := json.Unmarshal(b, &ref); err != nil {
+		return fmt.Errorf("secret must be a string or {\"file\": ...} or {\"env\": ...}")
+	}
+	if (ref.File == "") == (ref.Env == "") {
+		return fmt.Errorf("secret needs either file or env")
+	}
+	s.File, s.Env = ref.File, ref.Env
+	return nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) String() string {
+	switch {
+	case s.File != "":
+		return "file:" + s.File
+	case s.Env != "":
+		return "env:" + s.Env
+	}
+	return "[redacted]"
+}
+
+// key identifies s, including a literal value, but is never printed.
+func (s Secret) key() string {
+	return s.Value + "\x00" + s.File + "\x00" + s.Env
+}
+
+func (s Secret) set() bool {
+	return s.Value != "" || s.File != "" || s.Env != ""
+}
+
+func (s Secret) resolve() (string, error) {
+	switch {
+	case s.File != "":
+		b, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("secret %s not set", s.Env)
+		}
+		return v, nil
+	}
+	return s.Value, nil
+}
+
+// FeedAuth are the credentials sent with every request of a feed: basic auth
+// or a bearer token, additional headers and a client certificate.
+type FeedAuth struct {
+	Username string            `json:"username,omitempty"`
+	Password Secret            `json:"password,omitempty"`
+	Token    Secret            `json:"token,omitempty"`
+	Headers  map[string]Secret `json:"headers,omitempty"`
+	Cert     string            `json:"cert,omitempty"`
+	Key      string            `json:"key,omitempty"`
+}
+
+func (a *FeedAuth) validate() error {
+	if a.Token.set() && a.Username != "" {
+		return fmt.Errorf("auth with both username and token")
+	}
+	if (a.Cert == "") != (a.Key == "") {
+		return fmt.Errorf("auth needs both cert and key")
+	}
+	if a.Cert != "" {
+		if _, err := tls.LoadX509KeyPair(a.Cert, a.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key identifies the credentials of a, nil or not, including literal
+// secrets. It is only used to keep state per credentials.
+func (a *FeedAuth) key() string {
+	if a == nil {
+		return ""
+	}
+	parts := []string{a.Username, a.Password.key(), a.Token.key(), a.Cert, a.Key}
+	for _, name := range a.headerNames() {
+		parts = append(parts, name, a.Headers[name].key())
+	}
+	return strings.Join(parts, "\x01")
+}
+
+// String describes a without the values of its secrets.
+func (a *FeedAuth) String() string {
+	if a == nil {
+		return ""
+	}
+	var parts []string
+	if a.Username != "" {
+		parts = append(parts, "user "+a.Username)
+	}
+	if a.Token.set() {
+		parts = append(parts, "token "+a.Token.String())
+	}
+	for _, name := range a.headerNames() {
+		parts = append(parts, "header "+name)
+	}
+	if a.Cert != "" {
+		parts = append(parts, "cert "+a.Cert)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (a *FeedAuth) headerNames() []string {
+	names := make([]string, 0, len(a.Headers))
+	for name := range a.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply adds the credentials to request. A nil auth adds nothing.
+func (a *FeedAuth) apply(request *http.Request) error {
+	if a == nil {
+		return nil
+	}
+	for name, secret := range a.Headers {
+		v, err := secret.resolve()
+		if err != nil {
+			return err
+		}
+		request.Header.Set(name, v)
+	}
+	switch {
+	case a.Token.set():
+		token, err := a.Token.resolve()
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	case a.Username != "":
+		password, err := a.Password.resolve()
+		if err != nil {
+			return err
+		}
+		request.SetBasicAuth(a.Username, password)
+	}
+	return nil
+}
+
+// clientFor returns the client for requests with a: the fetcher's own one, or
+// one presenting the client certificate of a.
+func (f *feedFetcher) clientFor(a *FeedAuth) (*http.Client, error) {
+	if a == nil || a.Cert == "" {
+		return f.client, nil
+	}
+	key := a.Cert + "\x00" + a.Key
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[key]; ok {
+		return c, nil
+	}
+	cert, err := tls.LoadX509KeyPair(a.Cert, a.Key)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if t, ok := f.client.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	c := &http.Client{Transport: transport, Timeout: f.client.Timeout}
+	f.clients[key] = c
+	return c, nil
+}
+
+// secretParams are query parameters whose values are hidden in logs.
+var secretParams = []string{"token", "key", "password", "secret", "access_token", "apikey", "api_key", "auth"}
+
+// redactURL hides the password and secret query parameters of s.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	q := u.Query()
+	redacted := false
+	for name := range q {
+		for _, p := range secretParams {
+			if strings.EqualFold(name, p) {
+				q.Set(name, "xxxxx")
+				redacted = true
+			}
+		}
+	}
+	if redacted {
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// redactError hides the secrets of the URL in errors of the HTTP client.
+func redactError(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return &url.Error{Op: e.Op, URL: redactURL(e.URL), Err: e.Err}
+	}
+	return err
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var defaultBookingMinutes, maxBookingMinutes = 15, 120
+
+// Booking is where walk-up bookings of a display are created. For CalDAV,
+// URL is the calendar collection. Credentials are given in Auth, like those
+// of feeds, or in the URL. Token is the bearer token devices book with.
+type Booking struct {
+	Type  string    `json:"type"`
+	URL   string    `json:"url"`
+	Auth  *FeedAuth `json:"auth,omitempty"`
+	Token Secret    `json:"token,omitempty"`
+}
+
+// booker creates an event in the calendar of a room.
+type booker interface {
+	book(ctx context.Context, summary string, start, end time.Time) error
+}
+
+// bookingFetcher holds the clients of bookings, one per client certificate.
+var bookingFetcher = newFeedFetcher(&http.Client{Timeout: 30 * time.Second})
+
+var bookers = map[string]func(b Booking) booker{
+	"caldav": func(b Booking) booker { return caldavBooker{fetcher: bookingFetcher, url: b.URL, auth: b.Auth} },
+}
+
+func (b Booking) validate() error {
+	if _, ok := bookers[b.Type]; !ok {
+		return fmt.Errorf("unknown booking type %q", b.Type)
+	}
+	if b.URL == "" {
+		return fmt.Errorf("booking without url")
+	}
+	if b.Auth != nil {
+		return b.Auth.validate()
+	}
+	return nil
+}
+
+// authorized reports whether r may book: with the token of b as a bearer
+// token, or as an admin once $ADMIN_PASSWORD is configured.
+func (b Booking) authorized(r *http.Request) bool {
+	if b.Token.set() {
+		token, err := b.Token.resolve()
+		if err != nil {
+			log.Println("booking token", err)
+		} else if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1 {
+			return true
+		}
+	}
+	return os.Getenv("ADMIN_PASSWORD") != "" && isAdmin(r)
+}
+
+// caldavBooker creates events with a PUT into a CalDAV calendar collection.
+type caldavBooker struct {
+	fetcher *feedFetcher
+	url     string
+	auth    *FeedAuth
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`)
+
+// icsText returns s as a single line ICS text value, without control
+// characters.
+func icsText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+	return icsEscaper.Replace(strings.TrimSpace(s))
+}
+
+func newUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// bookingICS returns a calendar with a single event.
+func bookingICS(uid, summary string, start, end time.Time) []byte {
+	const stamp = "20060102T150405Z"
+	var b bytes.Buffer
+	fmt.Fprint(&b, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//paper//book now//EN\r\nBEGIN:VEVENT\r\n")
+	fmt.Fprintf(&b, "UID:%s\r\n", uid)
+	fmt.Fprintf(&b, "DTSTAMP:%s\r\n", time.Now().UTC().Format(stamp))
+	fmt.Fprintf(&b, "DTSTART:%s\r\n", start.UTC().Format(stamp))
+	fmt.Fprintf(&b, "DTEND:%s\r\n", end.UTC().Format(stamp))
+	fmt.Fprintf(&b, "SUMMARY:%s\r\n", icsText(summary))
+	fmt.Fprint(&b, "END:VEVENT\r\nEND:VCALENDAR\r\n")
+	return b.Bytes()
+}
+
+func (c caldavBooker) book(ctx context.Context, summary string, start, end time.Time) error {
+	uid := newUID()
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(c.url, "/")+"/"+uid+".ics", bytes.NewReader(bookingICS(uid, summary, start, end)))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if err := c.auth.apply(req); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	req.Header.Set("If-None-Match", "*")
+	client, err := c.fetcher.clientFor(c.auth)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("book: %s", resp.Status)
+	}
+	return nil
+}
+
+// bookingLocks serialize the bookings of each display so two walk-ups can't
+// claim the same slot, without a slow calendar holding up other rooms.
+var bookingLocks = struct {
+	sync.Mutex
+	byDisplay map[string]*sync.Mutex
+}{byDisplay: map[string]*sync.Mutex{}}
+
+func bookingLock(id string) *sync.Mutex {
+	bookingLocks.Lock()
+	defer bookingLocks.Unlock()
+	mu, ok := bookingLocks.byDisplay[id]
+	if !ok {
+		mu = &sync.Mutex{}
+		bookingLocks.byDisplay[id] = mu
+	}
+	return mu
+}
+
+// serveBook books the room of ?display= now for ?minutes= (default 15). The
+// calendar is refreshed before, to reject conflicts, and after, so the next
+// render shows the room as blocked.
+func serveBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	d, ok := displays.get(sanitize(q.Get("display")))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if d.Booking == nil {
+		http.Error(w, "booking not configured", http.StatusNotImplemented)
+		return
+	}
+	if !d.Booking.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="paper booking"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	minutes := defaultBookingMinutes
+	if s := q.Get("minutes"); s != "" {
+		m, err := strconv.Atoi(s)
+		if err != nil || m <= 0 || m > maxBookingMinutes {
+			http.Error(w, "invalid minutes", http.StatusBadRequest)
+			return
+		}
+		minutes = m
+	}
+	summary := q.Get("summary")
+	if strings.TrimSpace(summary) == "" {
+		summary = "Ad-hoc booking"
+	}
+
+	mu := bookingLock(d.ID)
+	mu.Lock()
+	defer mu.Unlock()
+	snapshot := calendars.refresh(d)
+	if snapshot.Err != nil {
+		log.Println(snapshot.Err)
+		http.Error(w, "calendar unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	start := time.Now().Truncate(time.Minute)
+	end := start.Add(time.Duration(minutes) * time.Minute)
+	if free, until := freeAt(snapshot.Events, start); !free || !until.IsZero() && until.Before(end) {
+		http.Error(w, "room is not free", http.StatusConflict)
+		return
+	}
+	if err := bookers[d.Booking.Type](*d.Booking).book(r.Context(), summary, start, end); err != nil {
+		log.Println(err)
+		http.Error(w, "booking failed", http.StatusBadGateway)
+		return
+	}
+	calendars.refresh(d)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err := json.NewEncoder(w).Encode(struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	}{start, end})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaldavBook(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("If-None-Match") != "*" || !strings.HasPrefix(r.URL.Path, "/cal/") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "room" || pass != "secret" {
+			t.Errorf("missing basic auth")
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	b := caldavBooker{fetcher: newFeedFetcher(srv.Client()), url: strings.Replace(srv.URL, "http://", "http://room:secret@", 1) + "/cal/"}
+	if err := b.book(context.Background(), "Stand-up, quick\r\nATTENDEE:x", start, start.Add(15*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"DTSTART:20261016T090000Z", "DTEND:20261016T091500Z", "SUMMARY:Stand-up\\, quick  ATTENDEE:x\r\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body misses %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestCaldavBookAuth(t *testing.T) {
+	os.Setenv("PAPER_TEST_SECRET", "from-env")
+	defer os.Unsetenv("PAPER_TEST_SECRET")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "room" || pass != "from-env" {
+			t.Errorf("missing basic auth")
+		}
+		if got := r.Header.Get("X-Api-Key"); got != "key" {
+			t.Errorf("header: got %q", got)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	auth := &FeedAuth{
+		Username: "room",
+		Password: Secret{Env: "PAPER_TEST_SECRET"},
+		Headers:  map[string]Secret{"X-Api-Key": {Value: "key"}},
+	}
+	b := caldavBooker{fetcher: newFeedFetcher(srv.Client()), url: srv.URL + "/cal/", auth: auth}
+	if err := b.book(context.Background(), "x", start, start.Add(15*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCaldavBookConflict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+	defer srv.Close()
+
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	b := caldavBooker{fetcher: newFeedFetcher(srv.Client()), url: srv.URL}
+	if err := b.book(context.Background(), "x", start, start.Add(15*time.Minute)); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestServeBook(t *testing.T) {
+	defer displays.set(displays.all())
+	defer calendars.retain(nil)
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var puts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		puts++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	const stamp = "20060102T150405Z"
+	now := time.Now().UTC()
+	tests := []struct {
+		start, end time.Time
+		want       int
+	}{
+		{now.Add(-10 * time.Minute), now.Add(30 * time.Minute), http.StatusConflict},
+		{now.Add(5 * time.Minute), now.Add(time.Hour), http.StatusConflict},
+		{now.Add(2 * time.Hour), now.Add(3 * time.Hour), http.StatusCreated},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("room%d.ics", i))
+		ics := fmt.Sprintf(caldavEvent, "meeting", tt.start.Format(stamp), tt.end.Format(stamp))
+		if err := ioutil.WriteFile(path, []byte(ics), 0644); err != nil {
+			t.Fatal(err)
+		}
+		displays.set(map[string]Display{"ROOM": {
+			ID:       "ROOM",
+			URL:      "file://" + path,
+			Timezone: "UTC",
+			Booking:  &Booking{Type: "caldav", URL: srv.URL + "/cal/", Token: Secret{Value: "device-token"}},
+		}})
+		calendars.retain(nil)
+
+		puts = 0
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/book?display=room&minutes=15", nil)
+		r.Header.Set("Authorization", "Bearer device-token")
+		serveBook(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s-%s: got status %d, want %d", tt.start, tt.end, w.Code, tt.want)
+		}
+		if booked := tt.want == http.StatusCreated; (puts == 1) != booked {
+			t.Errorf("%s-%s: got %d bookings", tt.start, tt.end, puts)
+		}
+	}
+}
+
+func TestServeBookUnauthorized(t *testing.T) {
+	defer displays.set(displays.all())
+	os.Setenv("ADMIN_USER", "admin")
+	os.Setenv("ADMIN_PASSWORD", "secret")
+	defer os.Unsetenv("ADMIN_USER")
+	defer os.Unsetenv("ADMIN_PASSWORD")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected booking %s %s", r.Method, r.URL)
+	}))
+	defer srv.Close()
+	displays.set(map[string]Display{"ROOM": {
+		ID:       "ROOM",
+		URL:      "file:///dev/null",
+		Timezone: "UTC",
+		Booking:  &Booking{Type: "caldav", URL: srv.URL, Token: Secret{Value: "device-token"}},
+	}})
+
+	tests := []struct {
+		name string
+		auth func(r *http.Request)
+	}{
+		{"none", func(r *http.Request) {}},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") }},
+		{"wrong admin", func(r *http.Request) { r.SetBasicAuth("admin", "guess") }},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/book?display=room", nil)
+		tt.auth(r)
+		w := httptest.NewRecorder()
+		serveBook(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got status %d, want 401", tt.name, w.Code)
+		}
+	}
+
+	// without admin credentials configured only the token books
+	os.Unsetenv("ADMIN_PASSWORD")
+	if (Booking{}).authorized(httptest.NewRequest(http.MethodPost, "/book", nil)) {
+		t.Error("booking without token or admin credentials authorized")
+	}
+	r := httptest.NewRequest(http.MethodPost, "/book", nil)
+	r.SetBasicAuth("admin", "secret")
+	os.Setenv("ADMIN_PASSWORD", "secret")
+	if !(Booking{}).authorized(r) {
+		t.Error("admin not authorized")
+	}
+}
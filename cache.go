@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PuloV/ics-golang"
+)
+
+var defaultRefreshInterval = 5 * time.Minute
+
+// refreshInterval returns $REFRESH_INTERVAL or the default if unset or invalid.
+func refreshInterval() time.Duration {
+	if s := os.Getenv("REFRESH_INTERVAL"); s != "" {
+		interval, err := time.ParseDuration(s)
+		if err == nil && interval > 0 {
+			return interval
+		}
+		log.Println("invalid REFRESH_INTERVAL", s)
+	}
+	return defaultRefreshInterval
+}
+
+// calendarEvent is an event of one of the feeds of a display.
+type calendarEvent struct {
+	ics.Event
+	Category string
+}
+
+// calendarSnapshot holds the merged events of the last successful fetch of
+// all feeds of a display, between From and To. Err is set when the latest
+// refresh failed, in which case Events and Fetched still describe the last
+// good state.
+type calendarSnapshot struct {
+	Events    []calendarEvent
+	From, To  time.Time
+	Fetched   time.Time
+	Attempted time.Time
+	Err       error
+}
+
+func (s calendarSnapshot) stale() bool {
+	return s.Err != nil && !s.Fetched.IsZero()
+}
+
+// covers reports whether the events of s include the day from t on.
+func (s calendarSnapshot) covers(t time.Time) bool {
+	return !t.Before(s.From) && !t.Add(24*time.Hour).After(s.To)
+}
+
+type calendarCache struct {
+	fetcher   *feedFetcher
+	clock     clock
+	mu        sync.RWMutex
+	snapshots map[string]*calendarSnapshot
+}
+
+func newCalendarCache(fetcher *feedFetcher) *calendarCache {
+	return &calendarCache{fetcher: fetcher, clock: systemClock{}, snapshots: map[string]*calendarSnapshot{}}
+}
+
+func (c *calendarCache) get(id string) (calendarSnapshot, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.snapshots[id]
+	if !ok {
+		return calendarSnapshot{}, false
+	}
+	return *s, true
+}
+
+// fetch merges the events of all feeds of d that occupy the room, from the
+// start of the day before now, to cover times parsed in another zone, until a
+// week ahead.
+func (c *calendarCache) fetch(d Display, now time.Time) (calendarSnapshot, error) {
+	tz, err := loadLocation(d.Timezone)
+	if err != nil {
+		return calendarSnapshot{}, err
+	}
+	now = now.In(tz)
+	s := calendarSnapshot{From: time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, tz)}
+	s.To = s.From.AddDate(0, 0, 8)
+
+	for _, f := range d.allFeeds() {
+		feedEvents, err := c.fetcher.source(f).events(s.From, s.To)
+		if err != nil {
+			return calendarSnapshot{}, err
+		}
+		s.Events = append(s.Events, d.calendarEvents(feedEvents, f.Category)...)
+	}
+	s.Fetched = time.Now()
+	s.Attempted = s.Fetched
+	return s, nil
+}
+
+// refresh fetches the feeds of d and stores the result. If any feed fails the
+// previous events are kept.
+func (c *calendarCache) refresh(d Display) calendarSnapshot {
+	fetched, err := c.fetch(d, c.clock.Now())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.snapshots[d.ID]
+	if !ok {
+		s = &calendarSnapshot{}
+		c.snapshots[d.ID] = s
+	}
+	s.Attempted = time.Now()
+	if err != nil {
+		log.Printf("refresh display=%s err=%s", d.ID, err)
+		s.Err = err
+		return *s
+	}
+	*s = fetched
+	return *s
+}
+
+// snapshotAt returns the calendar of d at t: the cached one, or a one-off
+// fetch around t if the cached range does not cover it. If that fails the
+// cached one is returned as stale.
+func (c *calendarCache) snapshotAt(d Display, t time.Time) calendarSnapshot {
+	snapshot, ok := c.get(d.ID)
+	if !ok {
+		snapshot = c.refresh(d)
+	}
+	if snapshot.Fetched.IsZero() || snapshot.covers(t) {
+		return snapshot
+	}
+	fetched, err := c.fetch(d, t)
+	if err != nil {
+		log.Printf("fetch display=%s at=%s err=%s", d.ID, t.Format(time.RFC3339), err)
+		snapshot.Err = err
+		return snapshot
+	}
+	return fetched
+}
+
+// run refreshes d every interval until ctx is done.
+func (c *calendarCache) run(ctx context.Context, d Display, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.refresh(d)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// start launches a background refresher for every display. Displays without
+// their own refresh interval use interval.
+func (c *calendarCache) start(ctx context.Context, displays map[string]Display, interval time.Duration) {
+	for _, d := range displays {
+		if len(d.allFeeds()) == 0 {
+			continue
+		}
+		i := interval
+		if d.RefreshInterval > 0 {
+			i = time.Duration(d.RefreshInterval)
+		}
+		go c.run(ctx, d, i)
+	}
+}
+
+// retain drops the snapshots of displays that are no longer configured.
+func (c *calendarCache) retain(displays map[string]Display) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.snapshots {
+		if _, ok := displays[id]; !ok {
+			delete(c.snapshots, id)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCalendarCacheKeepsEventsOnError(t *testing.T) {
+	defer calendars.retain(nil)
+	const stamp = "20060102T150405Z"
+	now := time.Now().UTC()
+	failing := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, caldavEvent, "planning", now.Add(-10*time.Minute).Format(stamp), now.Add(50*time.Minute).Format(stamp))
+	}))
+	defer srv.Close()
+	d := Display{ID: "ROOM", URL: srv.URL, Timezone: "UTC"}
+
+	good := calendars.refresh(d)
+	if good.Err != nil || len(good.Events) != 1 {
+		t.Fatalf("first refresh: got %d events, %v", len(good.Events), good.Err)
+	}
+	if schedule, err := displaySchedule(d, systemClock{}); err != nil || schedule.StaleSince != "" {
+		t.Errorf("fresh: got stale since %q, %v", schedule.StaleSince, err)
+	}
+
+	failing = true
+	s := calendars.refresh(d)
+	if s.Err == nil || !s.stale() {
+		t.Fatalf("failed refresh: got err %v", s.Err)
+	}
+	if len(s.Events) != 1 || !s.Fetched.Equal(good.Fetched) {
+		t.Errorf("failed refresh: got %d events fetched %v, want those of %v", len(s.Events), s.Fetched, good.Fetched)
+	}
+	schedule, err := displaySchedule(d, systemClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := good.Fetched.UTC().Format("15:04"); schedule.StaleSince != want {
+		t.Errorf("got stale since %q, want %q", schedule.StaleSince, want)
+	}
+	if !schedule.Blocked || schedule.Current == nil || schedule.Current.Summary != "planning" {
+		t.Errorf("got current %+v, want the kept meeting", schedule.Current)
+	}
+
+	failing = false
+	if s := calendars.refresh(d); s.Err != nil || s.stale() {
+		t.Errorf("recovered: got err %v", s.Err)
+	}
+}
+
+func TestCalendarCacheFirstError(t *testing.T) {
+	defer calendars.retain(nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	d := Display{ID: "ROOM", URL: srv.URL, Timezone: "UTC"}
+
+	if _, err := displaySchedule(d, systemClock{}); err == nil {
+		t.Error("expected error without any good fetch")
+	}
+	if s, ok := calendars.get(d.ID); !ok || s.Attempted.IsZero() || !s.Fetched.IsZero() {
+		t.Errorf("got snapshot %+v", s)
+	}
+}
+
+// setCalendar caches events as the calendar of id, fetched at at.
+func setCalendar(id string, at time.Time, events ...calendarEvent) {
+	calendars.mu.Lock()
+	defer calendars.mu.Unlock()
+	calendars.snapshots[id] = &calendarSnapshot{Events: events, From: at.AddDate(0, 0, -1), To: at.AddDate(0, 0, 7), Fetched: at}
+}
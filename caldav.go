@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuloV/ics-golang"
+)
+
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data/></d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT">
+        <c:time-range start="%s" end="%s"/>
+      </c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+// multistatus is the part of a calendar-query response we read.
+type multistatus struct {
+	Responses []struct {
+		Href string   `xml:"href"`
+		Data []string `xml:"propstat>prop>calendar-data"`
+	} `xml:"response"`
+}
+
+// caldavSource queries a CalDAV calendar collection for the events that
+// overlap a time range.
+type caldavSource struct {
+	fetcher *feedFetcher
+	feed    Feed
+}
+
+func (s caldavSource) events(start, end time.Time) ([]ics.Event, error) {
+	const stamp = "20060102T150405Z"
+	body := fmt.Sprintf(calendarQuery, start.UTC().Format(stamp), end.UTC().Format(stamp))
+	request, err := http.NewRequest("REPORT", s.feed.URL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	request.Header.Set("Depth", "1")
+	if err := s.feed.Auth.apply(request); err != nil {
+		return nil, err
+	}
+	client, err := s.fetcher.clientFor(s.feed.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, redactError(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("query calendar: %s", response.Status)
+	}
+	var ms multistatus
+	if err := xml.NewDecoder(response.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("query calendar: %v", err)
+	}
+
+	events := []ics.Event{}
+	for _, r := range ms.Responses {
+		for _, data := range r.Data {
+			e, err := parseEvents(data, r.Href)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, e...)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const caldavEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:%[1]s
+DTSTAMP:20261016T080000Z
+DTSTART:%[2]s
+DTEND:%[3]s
+SUMMARY:%[1]s
+END:VEVENT
+END:VCALENDAR
+`
+
+// caldavStub answers calendar-query reports with the events it was given,
+// like a server that filtered them by the requested range.
+func caldavStub(t *testing.T, check func(r *http.Request, body string), events ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		check(r, string(b))
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+		for i, e := range events {
+			fmt.Fprintf(w, `<d:response><d:href>/cal/%d.ics</d:href><d:propstat><d:prop><cal:calendar-data>%s</cal:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, i, e)
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+	}))
+}
+
+func TestCaldavSource(t *testing.T) {
+	srv := caldavStub(t, func(r *http.Request, body string) {
+		if r.Method != "REPORT" || r.Header.Get("Depth") != "1" {
+			t.Errorf("unexpected request %s depth %q", r.Method, r.Header.Get("Depth"))
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		if !strings.Contains(body, `<c:time-range start="20261016T000000Z" end="20261023T000000Z"/>`) {
+			t.Errorf("missing time range in %s", body)
+		}
+	},
+		fmt.Sprintf(caldavEvent, "standup", "20261016T090000Z", "20261016T091500Z"),
+		fmt.Sprintf(caldavEvent, "review", "20261017T140000Z", "20261017T150000Z"),
+	)
+	defer srv.Close()
+
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	s := caldavSource{fetcher: newFeedFetcher(srv.Client()), feed: Feed{URL: srv.URL + "/cal/", Type: "caldav", Auth: &FeedAuth{Token: Secret{Value: "token"}}}}
+	events, err := s.events(start, start.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if got := events[0].GetSummary(); got != "standup" {
+		t.Errorf("got summary %q", got)
+	}
+	if got := events[1].GetStart(); !got.Equal(time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("got start %v", got)
+	}
+}
+
+func TestCaldavSourceBasicAuth(t *testing.T) {
+	srv := caldavStub(t, func(r *http.Request, body string) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "room" || pass != "secret" {
+			t.Errorf("missing basic auth")
+		}
+	})
+	defer srv.Close()
+
+	s := caldavSource{fetcher: newFeedFetcher(srv.Client()), feed: Feed{URL: srv.URL, Auth: &FeedAuth{Username: "room", Password: Secret{Value: "secret"}}}}
+	events, err := s.events(time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("got %d events, want none", len(events))
+	}
+}
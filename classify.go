@@ -0,0 +1,86 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuloV/ics-golang"
+)
+
+// allDayPolicies are how displays treat all-day events: "ignore" (default)
+// leaves them out, "block" blocks the whole day like any other event.
+var allDayPolicies = map[string]bool{"": true, "ignore": true, "block": true}
+
+var reTransparent = regexp.MustCompile(`(?m)^TRANSP:TRANSPARENT\s*$`)
+var reUID = regexp.MustCompile(`(?m)^UID:(.*?)\s*$`)
+
+// transparentUIDs returns the UIDs of the events marked TRANSP:TRANSPARENT,
+// which the ics parser ignores.
+func transparentUIDs(content string) map[string]bool {
+	uids := map[string]bool{}
+	for _, block := range strings.Split(content, "BEGIN:VEVENT")[1:] {
+		if !reTransparent.MatchString(block) {
+			continue
+		}
+		if m := reUID.FindStringSubmatch(block); m != nil {
+			uids[m[1]] = true
+		}
+	}
+	return uids
+}
+
+// withoutTransparent drops the events of content that do not block time.
+func withoutTransparent(events []ics.Event, content string) []ics.Event {
+	transparent := transparentUIDs(content)
+	if len(transparent) == 0 {
+		return events
+	}
+	opaque := []ics.Event{}
+	for _, e := range events {
+		if !transparent[e.GetImportedID()] {
+			opaque = append(opaque, e)
+		}
+	}
+	return opaque
+}
+
+// occupies reports whether e blocks the room of d: cancelled events never
+// do, all-day events only if d blocks on them.
+func (d Display) occupies(e *ics.Event) bool {
+	if strings.EqualFold(e.GetStatus(), "CANCELLED") {
+		return false
+	}
+	if e.IsWholeDay() {
+		return d.AllDay == "block"
+	}
+	return true
+}
+
+// calendarEvents returns the events of a feed with category that occupy the
+// room of d, with floating times in the timezone of d.
+func (d Display) calendarEvents(events []ics.Event, category string) []calendarEvent {
+	loc, err := loadLocation(d.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	var occupying []calendarEvent
+	for i := range events {
+		if d.occupies(&events[i]) {
+			occupying = append(occupying, calendarEvent{resolveFloating(events[i], loc), category})
+		}
+	}
+	return occupying
+}
+
+// eventPattern returns how the blocks of e are drawn. Tentative events use
+// the "tentative" pattern, outline unless configured otherwise.
+func (d Display) eventPattern(e *calendarEvent) string {
+	if strings.EqualFold(e.GetStatus(), "TENTATIVE") {
+		if p, ok := d.Patterns["tentative"]; ok {
+			return p
+		}
+		return "outline"
+	}
+	return d.pattern(e.Category)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func fixtureEvents(t *testing.T, d Display) []calendarEvent {
+	start := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	events, err := fileSource{path: "testdata/classify.ics"}.events(start, start.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.calendarEvents(events, "")
+}
+
+func summaries(events []calendarEvent) map[string]bool {
+	s := map[string]bool{}
+	for i := range events {
+		s[events[i].GetSummary()] = true
+	}
+	return s
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		allDay string
+		want   []string
+	}{
+		{"", []string{"Standup", "Maybe review"}},
+		{"ignore", []string{"Standup", "Maybe review"}},
+		{"block", []string{"Standup", "Maybe review", "Company holiday"}},
+	}
+	for _, tt := range tests {
+		got := summaries(fixtureEvents(t, Display{AllDay: tt.allDay}))
+		if len(got) != len(tt.want) {
+			t.Errorf("allDay %q: got %v, want %v", tt.allDay, got, tt.want)
+			continue
+		}
+		for _, s := range tt.want {
+			if !got[s] {
+				t.Errorf("allDay %q: missing %s in %v", tt.allDay, s, got)
+			}
+		}
+	}
+}
+
+func TestTentativePattern(t *testing.T) {
+	d := Display{Timezone: "UTC", Window: Window{Start: "09:00"}}
+	events := fixtureEvents(t, d)
+	now := time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC)
+
+	schedule, err := buildSchedule(events, fixedClock(now), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Blocked {
+		t.Error("cancelled event blocks")
+	}
+	if !schedule.FreeUntil.Equal(time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("transparent event blocks, free until %v", schedule.FreeUntil)
+	}
+	// rows are 09:00 to 12:00, the tentative review starts in the last one
+	if p := schedule.BlockInfos[0].Patterns[0]; p != "solid" {
+		t.Errorf("standup: got pattern %q", p)
+	}
+	for _, info := range schedule.BlockInfos[1:3] {
+		for i, blocked := range info.Blocked {
+			if blocked {
+				t.Errorf("%s slot %d blocked", info.Time, i)
+			}
+		}
+	}
+
+	d.Window.Start = "13:00"
+	d.Patterns = map[string]string{"tentative": "hatched"}
+	schedule, err = buildSchedule(events, fixedClock(now), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := schedule.BlockInfos[0].Patterns[0]; p != "hatched" {
+		t.Errorf("configured tentative: got pattern %q", p)
+	}
+	d.Patterns = nil
+	schedule, _ = buildSchedule(events, fixedClock(now), d)
+	if p := schedule.BlockInfos[0].Patterns[0]; p != "outline" {
+		t.Errorf("tentative: got pattern %q", p)
+	}
+}
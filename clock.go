@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// clock tells the current time. Previews and tests use a fixed one.
+type clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// fixedClock always tells the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+var errAtNotAllowed = errors.New("at is only allowed for admins")
+
+// requestClock returns the clock of r: the time of ?at= (RFC 3339), which
+// only admins may set once $ADMIN_PASSWORD is configured, or the system clock.
+func requestClock(r *http.Request) (clock, error) {
+	s := r.URL.Query().Get("at")
+	if s == "" {
+		return systemClock{}, nil
+	}
+	if os.Getenv("ADMIN_PASSWORD") == "" || !isAdmin(r) {
+		return nil, errAtNotAllowed
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, errors.New("invalid at")
+	}
+	return fixedClock(t), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRequestClock(t *testing.T) {
+	os.Setenv("ADMIN_USER", "admin")
+	os.Setenv("ADMIN_PASSWORD", "secret")
+	defer os.Unsetenv("ADMIN_USER")
+	defer os.Unsetenv("ADMIN_PASSWORD")
+
+	r := httptest.NewRequest("GET", "/clock?display=ROOM1", nil)
+	if c, err := requestClock(r); err != nil || c != (systemClock{}) {
+		t.Errorf("without at: got %v, %v", c, err)
+	}
+
+	r = httptest.NewRequest("GET", "/clock?display=ROOM1&at=2026-10-19T08:00:00%2B02:00", nil)
+	if _, err := requestClock(r); err != errAtNotAllowed {
+		t.Errorf("without auth: got %v", err)
+	}
+	r.SetBasicAuth("admin", "secret")
+	c, err := requestClock(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC); !c.Now().Equal(want) {
+		t.Errorf("got %v, want %v", c.Now(), want)
+	}
+
+	os.Unsetenv("ADMIN_PASSWORD")
+	if _, err := requestClock(r); err != errAtNotAllowed {
+		t.Errorf("without admin password: got %v", err)
+	}
+	os.Setenv("ADMIN_PASSWORD", "secret")
+
+	r = httptest.NewRequest("GET", "/clock?at=tomorrow", nil)
+	r.SetBasicAuth("admin", "secret")
+	if _, err := requestClock(r); err == nil {
+		t.Error("invalid at: expected error")
+	}
+}
+
+func TestServeClockAt(t *testing.T) {
+	os.Setenv("ADMIN_PASSWORD", "secret")
+	defer os.Unsetenv("ADMIN_PASSWORD")
+
+	w := httptest.NewRecorder()
+	serveClock(w, httptest.NewRequest("GET", "/clock?at=2026-10-19T08:00:00Z", nil))
+	if w.Code != 403 {
+		t.Errorf("got status %d, want 403", w.Code)
+	}
+}
+
+func TestDisplayScheduleOutsideCache(t *testing.T) {
+	defer calendars.retain(nil)
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "room.ics")
+	ics := fmt.Sprintf(caldavEvent, "offsite", "20300107T090000Z", "20300107T120000Z")
+	if err := ioutil.WriteFile(path, []byte(ics), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := Display{ID: "ROOM", URL: "file://" + path, Timezone: "UTC"}
+
+	if _, err := displaySchedule(d, systemClock{}); err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := displaySchedule(d, fixedClock(time.Date(2030, 1, 7, 10, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schedule.Blocked || schedule.Current == nil || schedule.Current.Summary != "offsite" {
+		t.Errorf("got %+v, want the offsite in progress", schedule.Current)
+	}
+	if snapshot, _ := calendars.get(d.ID); snapshot.covers(time.Date(2030, 1, 7, 10, 0, 0, 0, time.UTC)) {
+		t.Error("the cached calendar was replaced by the preview")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Config is the content of the display configuration file ($CONFIG).
+type Config struct {
+	Displays []Display `json:"displays"`
+}
+
+// Duration is a time.Duration that is written as "5m" in the config file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// HexColor is a color written as "#rrggbb" in the config file. The zero
+// value, which is fully transparent, means unset.
+type HexColor color.RGBA
+
+func (c HexColor) RGBA() (r, g, b, a uint32) {
+	return color.RGBA(c).RGBA()
+}
+
+func (c HexColor) set() bool {
+	return c.A != 0
+}
+
+func (c *HexColor) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*c = HexColor{}
+		return nil
+	}
+	var r, g, bl uint8
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &bl); err != nil {
+		return fmt.Errorf("invalid color %q", s)
+	}
+	*c = HexColor{r, g, bl, 0xff}
+	return nil
+}
+
+func (c HexColor) MarshalJSON() ([]byte, error) {
+	if !c.set() {
+		return json.Marshal("")
+	}
+	return json.Marshal(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+}
+
+// Colors overrides the colors used to draw a display.
+type Colors struct {
+	Foreground HexColor `json:"foreground"`
+	Background HexColor `json:"background"`
+	Accent     HexColor `json:"accent"`
+}
+
+var defaultColors = Colors{
+	Foreground: HexColor(black),
+	Background: HexColor(white),
+	Accent:     HexColor(red),
+}
+
+// withDefaults fills every unset color from defaultColors.
+func (c Colors) withDefaults() Colors {
+	if !c.Foreground.set() {
+		c.Foreground = defaultColors.Foreground
+	}
+	if !c.Background.set() {
+		c.Background = defaultColors.Background
+	}
+	if !c.Accent.set() {
+		c.Accent = defaultColors.Accent
+	}
+	return c
+}
+
+var layouts = map[string]bool{"": true, "clock": true, "floor": true}
+
+func loadConfig(path string) (Config, error) {
+	var config Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, d := range config.Displays {
+		d.ID = sanitize(d.ID)
+		for j, room := range d.Rooms {
+			d.Rooms[j] = sanitize(room)
+		}
+		if d.Layout == "floor" {
+			if d.ID == "" || len(d.Rooms) == 0 || d.Timezone == "" {
+				return config, fmt.Errorf("%s: display %d needs id, rooms and timezone", path, i)
+			}
+		} else if d.ID == "" || len(d.allFeeds()) == 0 || d.Timezone == "" {
+			return config, fmt.Errorf("%s: display %d needs id, url or feeds and timezone", path, i)
+		}
+		for _, f := range d.allFeeds() {
+			if f.URL == "" {
+				return config, fmt.Errorf("%s: display %s: feed without url", path, d.ID)
+			}
+			if !feedTypes[f.Type] {
+				return config, fmt.Errorf("%s: display %s: unknown feed type %q", path, d.ID, f.Type)
+			}
+			if f.Auth != nil {
+				if err := f.Auth.validate(); err != nil {
+					return config, fmt.Errorf("%s: display %s: %v", path, d.ID, err)
+				}
+			}
+		}
+		for category, p := range d.Patterns {
+			if !patterns[p] {
+				return config, fmt.Errorf("%s: display %s: unknown pattern %q for %q", path, d.ID, p, category)
+			}
+		}
+		if _, err := loadLocation(d.Timezone); err != nil {
+			return config, fmt.Errorf("%s: display %s: %v", path, d.ID, err)
+		}
+		if err := d.Window.validate(); err != nil {
+			return config, fmt.Errorf("%s: display %s: %v", path, d.ID, err)
+		}
+		if _, ok := profiles[d.Profile]; d.Profile != "" && !ok {
+			return config, fmt.Errorf("%s: display %s: unknown profile %q", path, d.ID, d.Profile)
+		}
+		if err := validRotation(d.Rotation); err != nil {
+			return config, fmt.Errorf("%s: display %s: %v", path, d.ID, err)
+		}
+		if d.BusinessHours != nil {
+			if err := d.BusinessHours.validate(); err != nil {
+				return config, fmt.Errorf("%s: display %s: %v", path, d.ID, err)
+			}
+		}
+		if !allDayPolicies[d.AllDay] {
+			return config, fmt.Errorf("%s: display %s: unknown all-day policy %q", path, d.ID, d.AllDay)
+		}
+		if d.Booking != nil {
+			if err := d.Booking.validate(); err != nil {
+				return config, fmt.Errorf("%s: display %s: %v", path, d.ID, err)
+			}
+		}
+		if !layouts[d.Layout] {
+			return config, fmt.Errorf("%s: display %s: unknown layout %q", path, d.ID, d.Layout)
+		}
+		config.Displays[i] = d
+	}
+	return config, nil
+}
+
+// loadDisplays returns the displays from the environment overridden by the
+// displays of the config file at path, if any.
+func loadDisplays(path string) (map[string]Display, error) {
+	displays := envDisplays()
+	if path == "" {
+		return displays, nil
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range config.Displays {
+		displays[d.ID] = d
+	}
+	for _, d := range displays {
+		for _, id := range d.Rooms {
+			room, ok := displays[id]
+			if !ok {
+				return nil, fmt.Errorf("%s: display %s: unknown room %q", path, d.ID, id)
+			}
+			if room.Layout == "floor" {
+				return nil, fmt.Errorf("%s: display %s: room %q is a floor", path, d.ID, id)
+			}
+		}
+	}
+	return displays, nil
+}
+
+// watchConfig calls reload whenever the file at path changes or the process
+// receives SIGHUP, until ctx is done.
+func watchConfig(ctx context.Context, path string, interval time.Duration, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var modTime time.Time
+	if fi, err := os.Stat(path); err == nil {
+		modTime = fi.ModTime()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("SIGHUP, reloading", path)
+			reload()
+		case <-ticker.C:
+			fi, err := os.Stat(path)
+			if err != nil || fi.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = fi.ModTime()
+			log.Println("config changed, reloading", path)
+			reload()
+		}
+	}
+}
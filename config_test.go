@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, config string) {
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	display := func(fields string) string {
+		return `{"displays": [{"id": "room-1", "url": "file:///dev/null", "timezone": "Europe/Zurich"` + fields + `}]}`
+	}
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{display(""), ""},
+		{display(`, "window": {"start": "08:00", "end": "18:00", "slot": "15m"}, "profile": "epd42", "rotation": 90`), ""},
+		{`{"displays": [{"id": "room-1", "timezone": "UTC"}]}`, "needs id, url or feeds and timezone"},
+		{`{"displays": [{"url": "file:///dev/null", "timezone": "UTC"}]}`, "needs id, url or feeds and timezone"},
+		{display(`, "timezone": "Mars/Olympus"`), "unknown time zone"},
+		{display(`, "feeds": [{"category": "x"}]`), "feed without url"},
+		{display(`, "feeds": [{"url": "https://example.com", "type": "ftp"}]`), "unknown feed type"},
+		{display(`, "patterns": {"x": "dotted"}`), "unknown pattern"},
+		{display(`, "window": {"slot": "7m"}`), "does not divide an hour"},
+		{display(`, "profile": "epd99"`), "unknown profile"},
+		{display(`, "rotation": 45`), "rotation"},
+		{display(`, "allDay": "maybe"`), "unknown all-day policy"},
+		{display(`, "layout": "grid"`), "unknown layout"},
+		{display(`, "booking": {"type": "exchange", "url": "https://example.com"}`), "unknown booking type"},
+		{`{"displays": [`, "unexpected end"},
+	}
+	for _, tt := range tests {
+		writeConfig(t, path, tt.config)
+		config, err := loadConfig(path)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.config, err)
+			} else if config.Displays[0].ID != "ROOM1" {
+				t.Errorf("%s: got id %q, want it sanitized", tt.config, config.Displays[0].ID)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.config, err, tt.err)
+		}
+	}
+}
+
+func TestReloadDisplays(t *testing.T) {
+	defer displays.set(displays.all())
+	defer calendars.retain(nil)
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	writeConfig(t, path, `{"displays": [{"id": "room1", "name": "Before", "url": "file:///dev/null", "timezone": "UTC"}]}`)
+	reload := reloadDisplays(ctx, path)
+	reload()
+	if d, ok := displays.get("ROOM1"); !ok || d.Name != "Before" {
+		t.Fatalf("got %+v, %v", d, ok)
+	}
+
+	writeConfig(t, path, `{"displays": [{"id": "room1", "timezone": "UTC"}]}`)
+	reload()
+	if d, ok := displays.get("ROOM1"); !ok || d.Name != "Before" {
+		t.Errorf("invalid config: got %+v, want the previous displays", d)
+	}
+
+	writeConfig(t, path, `{"displays": [{"id": "room2", "name": "After", "url": "file:///dev/null", "timezone": "UTC"}]}`)
+	reload()
+	if _, ok := displays.get("ROOM1"); ok {
+		t.Error("removed display still configured")
+	}
+	if d, ok := displays.get("ROOM2"); !ok || d.Name != "After" {
+		t.Errorf("got %+v, %v", d, ok)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	writeConfig(t, path, `{}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloaded := make(chan bool, 1)
+	go watchConfig(ctx, path, 10*time.Millisecond, func() { reloaded <- true })
+	time.Sleep(50 * time.Millisecond)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Error("no reload after the config changed")
+	}
+}
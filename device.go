@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var defaultLowBattery = 3400
+
+// Telemetry is what a device reported on its last poll. Zero values mean the
+// device did not report them.
+type Telemetry struct {
+	Device     string    `json:"device,omitempty"`
+	BatteryMV  int       `json:"batteryMillivolts,omitempty"`
+	RSSI       int       `json:"rssi,omitempty"`
+	Firmware   string    `json:"firmware,omitempty"`
+	LastPoll   time.Time `json:"lastPoll"`
+	RemoteAddr string    `json:"remoteAddr"`
+}
+
+// lowBattery reports whether the battery is below the threshold of d.
+func (t Telemetry) lowBattery(d Display) bool {
+	threshold := defaultLowBattery
+	if d.LowBattery > 0 {
+		threshold = d.LowBattery
+	}
+	return t.BatteryMV > 0 && t.BatteryMV < threshold
+}
+
+// reported reports whether the request carried any device fields, unlike
+// one from a browser.
+func (t Telemetry) reported() bool {
+	return t.Device != "" || t.BatteryMV != 0 || t.RSSI != 0 || t.Firmware != ""
+}
+
+// requestValue returns the query parameter or, if unset, the header.
+func requestValue(r *http.Request, param, header string) string {
+	if v := r.URL.Query().Get(param); v != "" {
+		return v
+	}
+	return r.Header.Get(header)
+}
+
+func requestInt(r *http.Request, param, header string) int {
+	s := requestValue(r, param, header)
+	if s == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		log.Printf("invalid %s %q", param, s)
+		return 0
+	}
+	return v
+}
+
+func parseTelemetry(r *http.Request) Telemetry {
+	return Telemetry{
+		Device:     requestValue(r, "device", "X-Device-ID"),
+		BatteryMV:  requestInt(r, "battery", "X-Battery-MV"),
+		RSSI:       requestInt(r, "rssi", "X-RSSI"),
+		Firmware:   requestValue(r, "fw", "X-Firmware"),
+		LastPoll:   time.Now(),
+		RemoteAddr: r.RemoteAddr,
+	}
+}
+
+// deviceRegistry keeps the latest telemetry by display id.
+type deviceRegistry struct {
+	mu      sync.RWMutex
+	devices map[string]Telemetry
+}
+
+func newDeviceRegistry() *deviceRegistry {
+	return &deviceRegistry{devices: map[string]Telemetry{}}
+}
+
+func (r *deviceRegistry) report(display string, t Telemetry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices[display] = t
+}
+
+func (r *deviceRegistry) get(display string) (Telemetry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.devices[display]
+	return t, ok
+}
+
+func (r *deviceRegistry) all() map[string]Telemetry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	devices := make(map[string]Telemetry, len(r.devices))
+	for id, t := range r.devices {
+		devices[id] = t
+	}
+	return devices
+}
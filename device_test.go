@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLowBattery(t *testing.T) {
+	tests := []struct {
+		mv, threshold int
+		want          bool
+	}{
+		{0, 0, false},
+		{3300, 0, true},
+		{3400, 0, false},
+		{3500, 3600, true},
+		{3700, 3600, false},
+	}
+	for _, tt := range tests {
+		if got := (Telemetry{BatteryMV: tt.mv}).lowBattery(Display{LowBattery: tt.threshold}); got != tt.want {
+			t.Errorf("%d mV below %d: got %v, want %v", tt.mv, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceRegistry(t *testing.T) {
+	r := newDeviceRegistry()
+	if _, ok := r.get("ROOM"); ok {
+		t.Error("got telemetry before any report")
+	}
+	r.report("ROOM", Telemetry{Device: "a", BatteryMV: 3300})
+	r.report("ROOM", Telemetry{Device: "a", BatteryMV: 3900})
+	if got, ok := r.get("ROOM"); !ok || got.BatteryMV != 3900 {
+		t.Errorf("got %+v, want the latest report", got)
+	}
+	all := r.all()
+	all["OTHER"] = Telemetry{}
+	if _, ok := r.get("OTHER"); ok {
+		t.Error("all returned the registry itself")
+	}
+}
+
+func TestServeClockKeepsTelemetry(t *testing.T) {
+	defer displays.set(displays.all())
+	defer calendars.retain(nil)
+	defer func(old *deviceRegistry) { devices = old }(devices)
+	devices = newDeviceRegistry()
+	now := time.Now()
+	displays.set(map[string]Display{"ROOM": {ID: "ROOM", Timezone: "UTC"}})
+	setCalendar("ROOM", now)
+
+	serveClock(httptest.NewRecorder(), httptest.NewRequest("GET", "/clock?display=room&device=abc&battery=3300&rssi=-70", nil))
+	serveClock(httptest.NewRecorder(), httptest.NewRequest("GET", "/clock?display=room", nil))
+	got, ok := devices.get("ROOM")
+	if !ok || got.Device != "abc" || got.BatteryMV != 3300 || got.RSSI != -70 {
+		t.Errorf("got %+v, want the device report kept", got)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Display struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	URL             string            `json:"url,omitempty"`
+	Feeds           []Feed            `json:"feeds,omitempty"`
+	Timezone        string            `json:"timezone"`
+	Layout          string            `json:"layout,omitempty"`
+	RefreshInterval Duration          `json:"refreshInterval,omitempty"`
+	Colors          Colors            `json:"colors"`
+	Patterns        map[string]string `json:"patterns,omitempty"`
+	Private         bool              `json:"private,omitempty"`
+	Window          Window            `json:"window"`
+	Profile         string            `json:"profile,omitempty"`
+	Rotation        int               `json:"rotation,omitempty"`
+	BusinessHours   *BusinessHours    `json:"businessHours,omitempty"`
+	MaxSleep        Duration          `json:"maxSleep,omitempty"`
+	LowBattery      int               `json:"lowBatteryMillivolts,omitempty"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	Rooms           []string          `json:"rooms,omitempty"`
+	Booking         *Booking          `json:"booking,omitempty"`
+	AllDay          string            `json:"allDay,omitempty"`
+}
+
+// profile returns the panel profile of d with the display rotation applied.
+func (d Display) profile() Profile {
+	p, ok := profiles[d.Profile]
+	if !ok {
+		p = defaultProfile
+	}
+	p.Rotation = (p.Rotation + d.Rotation) % 360
+	return p
+}
+
+var defaultHours, defaultSlotsPerHour = 4, 12
+
+// minSlot keeps the grid at no more than 60 blocks per row.
+var minSlot = time.Minute
+
+// Window is the range of hours shown in the grid, one row per hour. Without
+// a start the window begins at the current hour.
+type Window struct {
+	Start string   `json:"start,omitempty"`
+	End   string   `json:"end,omitempty"`
+	Hours int      `json:"hours,omitempty"`
+	Slot  Duration `json:"slot,omitempty"`
+}
+
+func parseHour(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil || t.Minute() != 0 {
+		return 0, fmt.Errorf("invalid hour %q", s)
+	}
+	return t.Hour(), nil
+}
+
+func (w Window) startHour() (int, bool) {
+	h, err := parseHour(w.Start)
+	return h, err == nil
+}
+
+func (w Window) hours() int {
+	if w.Hours > 0 {
+		return w.Hours
+	}
+	start, err1 := parseHour(w.Start)
+	end, err2 := parseHour(w.End)
+	if err1 == nil && err2 == nil && end > start {
+		return end - start
+	}
+	return defaultHours
+}
+
+func (w Window) slotsPerHour() int {
+	if w.Slot <= 0 {
+		return defaultSlotsPerHour
+	}
+	return int(time.Hour / time.Duration(w.Slot))
+}
+
+func (w Window) validate() error {
+	if w.Start != "" {
+		if _, err := parseHour(w.Start); err != nil {
+			return err
+		}
+	}
+	if w.End != "" {
+		if w.Start == "" {
+			return fmt.Errorf("window end without start")
+		}
+		end, err := parseHour(w.End)
+		if err != nil {
+			return err
+		}
+		if start, _ := parseHour(w.Start); end <= start {
+			return fmt.Errorf("window end %s not after start %s", w.End, w.Start)
+		}
+		if w.Hours > 0 {
+			return fmt.Errorf("window has both end and hours")
+		}
+	}
+	if w.Hours < 0 {
+		return fmt.Errorf("negative window hours %d", w.Hours)
+	}
+	if w.hours() > 24 {
+		return fmt.Errorf("window longer than 24 hours")
+	}
+	if w.Slot < 0 || w.Slot > 0 && time.Hour%time.Duration(w.Slot) != 0 {
+		return fmt.Errorf("slot %s does not divide an hour", time.Duration(w.Slot))
+	}
+	if w.Slot > 0 && time.Duration(w.Slot) < minSlot {
+		return fmt.Errorf("slot %s shorter than %s", time.Duration(w.Slot), minSlot)
+	}
+	return nil
+}
+
+// Feed is one calendar of a display. Events of a feed with a category are
+// drawn with the pattern configured for that category.
+type Feed struct {
+	URL      string    `json:"url"`
+	Category string    `json:"category,omitempty"`
+	Type     string    `json:"type,omitempty"`
+	Auth     *FeedAuth `json:"auth,omitempty"`
+}
+
+// allFeeds returns Feeds plus the single URL shorthand, if set.
+func (d Display) allFeeds() []Feed {
+	feeds := d.Feeds
+	if d.URL != "" {
+		feeds = append([]Feed{{URL: d.URL}}, feeds...)
+	}
+	return feeds
+}
+
+var patterns = map[string]bool{"solid": true, "hatched": true, "outline": true}
+
+// pattern returns how blocks of category are drawn. Uncategorized events are
+// solid, other categories are hatched unless configured otherwise.
+func (d Display) pattern(category string) string {
+	if p, ok := d.Patterns[category]; ok {
+		return p
+	}
+	if category == "" {
+		return "solid"
+	}
+	return "hatched"
+}
+
+// displayRegistry holds the currently configured displays by id.
+type displayRegistry struct {
+	mu       sync.RWMutex
+	displays map[string]Display
+}
+
+func newDisplayRegistry(displays map[string]Display) *displayRegistry {
+	return &displayRegistry{displays: displays}
+}
+
+func (r *displayRegistry) get(id string) (Display, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.displays[id]
+	return d, ok
+}
+
+func (r *displayRegistry) all() map[string]Display {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	displays := make(map[string]Display, len(r.displays))
+	for id, d := range r.displays {
+		displays[id] = d
+	}
+	return displays
+}
+
+func (r *displayRegistry) set(displays map[string]Display) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.displays = displays
+}
+
+var displayEnvURL = regexp.MustCompile(`^DISPLAY_([0-9A-Z]+)_URL=`)
+
+// envDisplay reads the DISPLAY_<id>_* variables for a sanitized display id.
+func envDisplay(id string) (Display, bool) {
+	d := Display{
+		ID:       id,
+		Name:     os.Getenv("DISPLAY_" + id + "_NAME"),
+		URL:      os.Getenv("DISPLAY_" + id + "_URL"),
+		Timezone: os.Getenv("DISPLAY_" + id + "_TZ"),
+	}
+	if os.Getenv("DISPLAY_"+id+"_OTZ") != "" {
+		log.Printf("DISPLAY_%s_OTZ is ignored, event times are read in their own timezone", id)
+	}
+	return d, d.URL != "" && d.Timezone != ""
+}
+
+// envDisplays returns every display configured through the environment.
+func envDisplays() map[string]Display {
+	displays := map[string]Display{}
+	for _, kv := range os.Environ() {
+		m := displayEnvURL.FindStringSubmatch(kv)
+		if m == nil {
+			continue
+		}
+		if d, ok := envDisplay(m[1]); ok {
+			displays[d.ID] = d
+		}
+	}
+	return displays
+}
+
+var notWhitelist = regexp.MustCompile(`[^0-9A-Z]`)
+
+func sanitize(s string) string {
+	return notWhitelist.ReplaceAllString(strings.ToUpper(s), "")
+}
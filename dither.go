@@ -0,0 +1,110 @@
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+var dithers = map[string]bool{"none": true, "ordered": true, "fs": true}
+
+// yccColor is a palette color in YCbCr. Matching in YCbCr keeps gray
+// anti-aliasing on black or white instead of a saturated color like red.
+type yccColor struct {
+	y, cb, cr float32
+}
+
+func toYCC(r, g, b float32) yccColor {
+	return yccColor{
+		y:  0.299*r + 0.587*g + 0.114*b,
+		cb: -0.168736*r - 0.331264*g + 0.5*b,
+		cr: 0.5*r - 0.418688*g - 0.081312*b,
+	}
+}
+
+func nearest(palette []yccColor, r, g, b float32) int {
+	c := toYCC(r, g, b)
+	best, bestDist := 0, float32(-1)
+	for i, p := range palette {
+		dy, dcb, dcr := c.y-p.y, c.cb-p.cb, c.cr-p.cr
+		dist := dy*dy + dcb*dcb + dcr*dcr
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+var bayer4 = [4][4]float32{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// quantize maps img onto palette using dither "none", "ordered" (4x4 Bayer)
+// or "fs" (Floyd-Steinberg).
+func quantize(img image.Image, palette color.Palette, dither string) *image.Paletted {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+
+	ycc := make([]yccColor, len(palette))
+	rgb := make([][3]float32, len(palette))
+	for i, c := range palette {
+		r, g, bl, _ := c.RGBA()
+		rgb[i] = [3]float32{float32(r >> 8), float32(g >> 8), float32(bl >> 8)}
+		ycc[i] = toYCC(rgb[i][0], rgb[i][1], rgb[i][2])
+	}
+
+	// pixel values plus the error diffused so far
+	px := make([][3]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if rgba, ok := img.(*image.RGBA); ok {
+				c := rgba.RGBAAt(b.Min.X+x, b.Min.Y+y)
+				px[y*w+x] = [3]float32{float32(c.R), float32(c.G), float32(c.B)}
+				continue
+			}
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			px[y*w+x] = [3]float32{float32(r >> 8), float32(g >> 8), float32(bl >> 8)}
+		}
+	}
+
+	spread := float32(255)
+	if len(palette) > 2 {
+		spread /= float32(len(palette) - 1)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := px[y*w+x]
+			if dither == "ordered" {
+				offset := ((bayer4[y%4][x%4]+0.5)/16 - 0.5) * spread
+				p[0], p[1], p[2] = p[0]+offset, p[1]+offset, p[2]+offset
+			}
+			i := nearest(ycc, p[0], p[1], p[2])
+			out.Pix[y*out.Stride+x] = uint8(i)
+			if dither != "fs" {
+				continue
+			}
+			var e [3]float32
+			for c := range e {
+				e[c] = p[c] - rgb[i][c]
+			}
+			diffuse := func(dx, dy int, f float32) {
+				nx, ny := x+dx, y+dy
+				if nx < 0 || nx >= w || ny >= h {
+					return
+				}
+				q := &px[ny*w+nx]
+				for c := range e {
+					q[c] += e[c] * f
+				}
+			}
+			diffuse(1, 0, 7.0/16)
+			diffuse(-1, 1, 3.0/16)
+			diffuse(0, 1, 5.0/16)
+			diffuse(1, 1, 1.0/16)
+		}
+	}
+	return out
+}
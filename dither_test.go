@@ -0,0 +1,39 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestQuantizeKeepsGrayOffAccent(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			v := uint8(x * 16)
+			img.Set(x, y, color.RGBA{v, v, v, 0xff})
+		}
+	}
+	for _, dither := range []string{"none", "ordered", "fs"} {
+		out := quantize(img, paletteBWR, dither)
+		for i, p := range out.Pix {
+			if p == 2 {
+				t.Fatalf("%s: gray pixel %d quantized to red", dither, i)
+			}
+		}
+	}
+}
+
+func TestQuantizeNone(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.RGBA{0x20, 0x20, 0x20, 0xff})
+	img.Set(1, 0, color.RGBA{0xf0, 0xf0, 0xf0, 0xff})
+	img.Set(2, 0, color.RGBA{0xe0, 0x10, 0x10, 0xff})
+	out := quantize(img, paletteBWR, "none")
+	want := []uint8{1, 0, 2}
+	for i, p := range out.Pix {
+		if p != want[i] {
+			t.Errorf("pixel %d = %d, want %d", i, p, want[i])
+		}
+	}
+}
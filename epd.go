@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// epdMagic starts every epd response, the last byte is the format version.
+var epdMagic = [4]byte{'E', 'P', 'D', 1}
+
+const (
+	epdFlagLSBFirst = 1 << iota
+	epdFlagInverted
+)
+
+// epdHeader precedes the black and the red plane, all fields little endian.
+type epdHeader struct {
+	Magic  [4]byte
+	Width  uint16
+	Height uint16
+	Stride uint16 // bytes per row including padding
+	Flags  uint8
+	Planes uint8
+}
+
+// epdOptions describes the bit layout the panel controller expects.
+type epdOptions struct {
+	LSBFirst bool // first pixel in the least significant bit
+	Inverted bool // 0 means ink instead of 1
+	RowAlign int  // rows are padded to a multiple of RowAlign bytes
+}
+
+func parseEPDOptions(q url.Values) (epdOptions, error) {
+	opts := epdOptions{RowAlign: 1}
+	switch q.Get("bitorder") {
+	case "", "msb":
+	case "lsb":
+		opts.LSBFirst = true
+	default:
+		return opts, fmt.Errorf("invalid bitorder %q", q.Get("bitorder"))
+	}
+	switch q.Get("invert") {
+	case "", "0", "false":
+	case "1", "true":
+		opts.Inverted = true
+	default:
+		return opts, fmt.Errorf("invalid invert %q", q.Get("invert"))
+	}
+	if s := q.Get("pad"); s != "" {
+		align, err := strconv.Atoi(s)
+		if err != nil || align < 1 || align > 64 {
+			return opts, fmt.Errorf("invalid pad %q", s)
+		}
+		opts.RowAlign = align
+	}
+	return opts, nil
+}
+
+func (o epdOptions) stride(width int) int {
+	stride := (width + 7) / 8
+	return (stride + o.RowAlign - 1) / o.RowAlign * o.RowAlign
+}
+
+// encodeEPD writes img as a header followed by a packed black plane and a
+// packed red plane. Every pixel goes to the plane of the nearest of the
+// display colors, background pixels are in neither.
+func encodeEPD(w io.Writer, img image.Image, colors Colors, opts epdOptions) error {
+	b := img.Bounds()
+	stride := opts.stride(b.Dx())
+	header := epdHeader{
+		Magic:  epdMagic,
+		Width:  uint16(b.Dx()),
+		Height: uint16(b.Dy()),
+		Stride: uint16(stride),
+		Planes: 2,
+	}
+	if opts.LSBFirst {
+		header.Flags |= epdFlagLSBFirst
+	}
+	if opts.Inverted {
+		header.Flags |= epdFlagInverted
+	}
+
+	black := make([]byte, stride*b.Dy())
+	red := make([]byte, stride*b.Dy())
+	if opts.Inverted {
+		for i := range black {
+			black[i], red[i] = 0xff, 0xff
+		}
+	}
+	palette := color.Palette{colors.Background, colors.Foreground, colors.Accent}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var plane []byte
+			switch palette.Index(img.At(b.Min.X+x, b.Min.Y+y)) {
+			case 1:
+				plane = black
+			case 2:
+				plane = red
+			default:
+				continue
+			}
+			bit := byte(0x80 >> uint(x%8))
+			if opts.LSBFirst {
+				bit = 1 << uint(x%8)
+			}
+			plane[y*stride+x/8] ^= bit
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	bw.Write(black)
+	bw.Write(red)
+	return bw.Flush()
+}
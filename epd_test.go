@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestEncodeEPD(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 2))
+	for x := 0; x < 10; x++ {
+		img.Set(x, 0, white)
+		img.Set(x, 1, white)
+	}
+	img.Set(0, 0, black)
+	img.Set(9, 0, black)
+	img.Set(1, 1, red)
+
+	tests := []struct {
+		name  string
+		opts  epdOptions
+		black []byte
+		red   []byte
+	}{
+		{"msb", epdOptions{RowAlign: 1}, []byte{0x80, 0x40, 0, 0}, []byte{0, 0, 0x40, 0}},
+		{"lsb", epdOptions{LSBFirst: true, RowAlign: 1}, []byte{0x01, 0x02, 0, 0}, []byte{0, 0, 0x02, 0}},
+		{"inverted", epdOptions{Inverted: true, RowAlign: 1}, []byte{0x7f, 0xbf, 0xff, 0xff}, []byte{0xff, 0xff, 0xbf, 0xff}},
+		{"padded", epdOptions{RowAlign: 4}, []byte{0x80, 0x40, 0, 0, 0, 0, 0, 0}, []byte{0, 0, 0, 0, 0x40, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeEPD(&buf, img, defaultColors, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.Bytes()
+			if !bytes.Equal(out[:4], epdMagic[:]) {
+				t.Fatalf("magic = %v", out[:4])
+			}
+			planes := out[12:]
+			if len(planes) != len(tt.black)+len(tt.red) {
+				t.Fatalf("got %d plane bytes, want %d", len(planes), len(tt.black)+len(tt.red))
+			}
+			if got := planes[:len(tt.black)]; !bytes.Equal(got, tt.black) {
+				t.Errorf("black = %x, want %x", got, tt.black)
+			}
+			if got := planes[len(tt.black):]; !bytes.Equal(got, tt.red) {
+				t.Errorf("red = %x, want %x", got, tt.red)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuloV/ics-golang"
+)
+
+// feedState remembers the validators and parsed events of the last
+// successful download of a feed.
+type feedState struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Requests     int       `json:"requests"`
+	NotModified  int       `json:"notModified"`
+	LastChanged  time.Time `json:"lastChanged"`
+	events       []ics.Event
+	url, auth    string
+}
+
+// feedFetcher downloads ICS feeds with conditional requests and only parses
+// bodies that changed since the previous fetch.
+type feedFetcher struct {
+	client  *http.Client
+	mu      sync.Mutex
+	feeds   map[string]*feedState
+	clients map[string]*http.Client
+}
+
+func newFeedFetcher(client *http.Client) *feedFetcher {
+	return &feedFetcher{client: client, feeds: map[string]*feedState{}, clients: map[string]*http.Client{}}
+}
+
+// the ics parser keeps global state, so parsing is serialized
+var parseMu sync.Mutex
+
+// parseEvents returns the events of an ICS calendar that block time, never
+// nil. Times are instants, except floating ones which are left to the display.
+func parseEvents(content, url string) ([]ics.Event, error) {
+	// the parser only reads the times of LF terminated lines
+	content = strings.Replace(content, "\r\n", "\n", -1)
+	zones := vtimezones(content)
+	parseMu.Lock()
+	calendar, _, err := ics.ParseICalContent(markFloating(content), url)
+	parseMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	events := calendar.GetEvents()
+	if events == nil {
+		events = []ics.Event{}
+	}
+	resolveTimes(events, zones)
+	return withoutTransparent(events, content), nil
+}
+
+// state returns the state of feed, kept per URL and credentials since the
+// server may answer differently for each.
+func (f *feedFetcher) state(feed Feed) *feedState {
+	key := feed.URL + "\x00" + feed.Auth.key()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.feeds[key]
+	if !ok {
+		s = &feedState{url: feed.URL, auth: feed.Auth.String()}
+		f.feeds[key] = s
+	}
+	return s
+}
+
+func (f *feedFetcher) fetch(feed Feed) ([]ics.Event, error) {
+	url := feed.URL
+	s := f.state(feed)
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := feed.Auth.apply(request); err != nil {
+		return nil, err
+	}
+	client, err := f.clientFor(feed.Auth)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	if s.events != nil {
+		if s.ETag != "" {
+			request.Header.Set("If-None-Match", s.ETag)
+		}
+		if s.LastModified != "" {
+			request.Header.Set("If-Modified-Since", s.LastModified)
+		}
+	}
+	s.Requests++
+	f.mu.Unlock()
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, redactError(err)
+	}
+	// close the response body
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		s.NotModified++
+		log.Printf("feed unchanged url=%s requests=%d notModified=%d", redactURL(s.url), s.Requests, s.NotModified)
+		return s.events, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch calendar: %s", response.Status)
+	}
+
+	icsBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := parseEvents(string(icsBytes), url)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s.ETag = response.Header.Get("ETag")
+	s.LastModified = response.Header.Get("Last-Modified")
+	s.LastChanged = time.Now()
+	s.events = events
+	return events, nil
+}
+
+// stats returns a copy of the per feed counters keyed by url, with secrets
+// in the url redacted, and the credentials if the feed has any.
+func (f *feedFetcher) stats() map[string]feedState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := make(map[string]feedState, len(f.feeds))
+	for _, s := range f.feeds {
+		key := redactURL(s.url)
+		if s.auth != "" {
+			key += " (" + s.auth + ")"
+		}
+		stats[key] = feedState{
+			ETag:         s.ETag,
+			LastModified: s.LastModified,
+			Requests:     s.Requests,
+			NotModified:  s.NotModified,
+			LastChanged:  s.LastChanged,
+		}
+	}
+	return stats
+}
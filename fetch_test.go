@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchNotModified(t *testing.T) {
+	const etag, lastModified = `"v1"`, "Fri, 16 Oct 2026 08:00:00 GMT"
+	var conditional []bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match") != "")
+		if r.Header.Get("If-None-Match") == etag && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		summary := "public"
+		if r.Header.Get("Authorization") != "" {
+			summary = "private"
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		fmt.Fprintf(w, caldavEvent, summary, "20261016T090000Z", "20261016T100000Z")
+	}))
+	defer srv.Close()
+
+	f := newFeedFetcher(srv.Client())
+	public := Feed{URL: srv.URL}
+	private := Feed{URL: srv.URL, Auth: &FeedAuth{Token: Secret{Value: "secret"}}}
+	for i, feed := range []Feed{public, public, private, private} {
+		events, err := f.fetch(feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "public"
+		if feed.Auth != nil {
+			want = "private"
+		}
+		if len(events) != 1 || events[0].GetSummary() != want {
+			t.Errorf("fetch %d: got %v, want %s", i, events, want)
+		}
+	}
+	if want := []bool{false, true, false, true}; fmt.Sprint(conditional) != fmt.Sprint(want) {
+		t.Errorf("conditional requests: got %v, want %v", conditional, want)
+	}
+
+	stats := f.stats()
+	if len(stats) != 2 {
+		t.Fatalf("got stats %v, want one per credentials", stats)
+	}
+	for key, s := range stats {
+		if s.Requests != 2 || s.NotModified != 1 || s.ETag != etag {
+			t.Errorf("%s: got %+v", key, s)
+		}
+		if strings.Contains(key, "secret") {
+			t.Errorf("stats key %s shows the token", key)
+		}
+	}
+}
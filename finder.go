@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagFilter matches a display tag, either exactly ("floor=3") or as a
+// minimum ("capacity>=8").
+type tagFilter struct {
+	key   string
+	value string
+	min   bool
+}
+
+func parseTagFilter(s string) (tagFilter, error) {
+	if i := strings.Index(s, ">="); i > 0 {
+		f := tagFilter{key: s[:i], value: s[i+2:], min: true}
+		if _, err := strconv.ParseFloat(f.value, 64); err != nil {
+			return f, fmt.Errorf("invalid tag filter %q", s)
+		}
+		return f, nil
+	}
+	if i := strings.Index(s, "="); i > 0 {
+		return tagFilter{key: s[:i], value: s[i+1:]}, nil
+	}
+	return tagFilter{}, fmt.Errorf("invalid tag filter %q", s)
+}
+
+func (f tagFilter) matches(tags map[string]string) bool {
+	v, ok := tags[f.key]
+	if !ok {
+		return false
+	}
+	if !f.min {
+		return v == f.value
+	}
+	have, err := strconv.ParseFloat(v, 64)
+	want, _ := strconv.ParseFloat(f.value, 64)
+	return err == nil && have >= want
+}
+
+// freeAt reports whether no event covers at and until when the room stays
+// free. A zero time means no later event is known.
+func freeAt(events []calendarEvent, at time.Time) (bool, time.Time) {
+	var until time.Time
+	for i := range events {
+		start, end := events[i].GetStart(), events[i].GetEnd()
+		if !start.After(at) && end.After(at) {
+			return false, time.Time{}
+		}
+		if start.After(at) && (until.IsZero() || start.Before(until)) {
+			until = start
+		}
+	}
+	return true, until
+}
+
+type freeRoom struct {
+	Display     string            `json:"display"`
+	Name        string            `json:"name"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	FreeUntil   *time.Time        `json:"freeUntil,omitempty"`
+	FreeMinutes *int              `json:"freeMinutes,omitempty"`
+}
+
+// serveFreeRooms lists the rooms that are free for at least ?minutes= (default
+// 30) from ?at= (RFC 3339, default now), longest free first. Every ?tag=
+// filter must match.
+func serveFreeRooms(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	minutes := 30
+	if s := q.Get("minutes"); s != "" {
+		m, err := strconv.Atoi(s)
+		if err != nil || m < 0 {
+			http.Error(w, "invalid minutes", http.StatusBadRequest)
+			return
+		}
+		minutes = m
+	}
+	at := time.Now()
+	if s := q.Get("at"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "invalid at", http.StatusBadRequest)
+			return
+		}
+		at = t
+	}
+	var filters []tagFilter
+	for _, s := range q["tag"] {
+		f, err := parseTagFilter(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filters = append(filters, f)
+	}
+
+	rooms := []freeRoom{}
+DisplayLoop:
+	for _, d := range displays.all() {
+		if d.Layout == "floor" {
+			continue
+		}
+		for _, f := range filters {
+			if !f.matches(d.Tags) {
+				continue DisplayLoop
+			}
+		}
+		snapshot, ok := calendars.get(d.ID)
+		if !ok || snapshot.Fetched.IsZero() {
+			continue
+		}
+		// rooms whose calendar isn't known at that time are left out
+		if !snapshot.covers(at) {
+			if snapshot = calendars.snapshotAt(d, at); !snapshot.covers(at) {
+				continue
+			}
+		}
+		free, until := freeAt(snapshot.Events, at)
+		if !free || !until.IsZero() && until.Sub(at) < time.Duration(minutes)*time.Minute {
+			continue
+		}
+		room := freeRoom{Display: d.ID, Name: d.Name, Tags: d.Tags}
+		if !until.IsZero() {
+			m := int(until.Sub(at) / time.Minute)
+			room.FreeUntil, room.FreeMinutes = &until, &m
+		}
+		rooms = append(rooms, room)
+	}
+	// rooms without a known next meeting are free the longest
+	sort.Slice(rooms, func(i, j int) bool {
+		a, b := rooms[i].FreeMinutes, rooms[j].FreeMinutes
+		switch {
+		case a == nil && b == nil:
+		case a == nil || b == nil:
+			return a == nil
+		case *a != *b:
+			return *a > *b
+		}
+		return rooms[i].Display < rooms[j].Display
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rooms); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PuloV/ics-golang"
+)
+
+func testEvent(start, end time.Time) calendarEvent {
+	e := ics.NewEvent()
+	e.SetStart(start)
+	e.SetEnd(end)
+	return calendarEvent{Event: *e}
+}
+
+func TestFreeAt(t *testing.T) {
+	base := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	events := []calendarEvent{
+		testEvent(base, base.Add(time.Hour)),
+		testEvent(base.Add(3*time.Hour), base.Add(4*time.Hour)),
+	}
+	tests := []struct {
+		at    time.Time
+		free  bool
+		until time.Time
+	}{
+		{base.Add(30 * time.Minute), false, time.Time{}},
+		{base.Add(time.Hour), true, base.Add(3 * time.Hour)},
+		{base.Add(5 * time.Hour), true, time.Time{}},
+	}
+	for _, tt := range tests {
+		free, until := freeAt(events, tt.at)
+		if free != tt.free || !until.Equal(tt.until) {
+			t.Errorf("freeAt(%s) = %v, %s, want %v, %s", tt.at, free, until, tt.free, tt.until)
+		}
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	tags := map[string]string{"floor": "3", "capacity": "8"}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"floor=3", true},
+		{"floor=4", false},
+		{"capacity>=8", true},
+		{"capacity>=10", false},
+		{"projector=yes", false},
+	}
+	for _, tt := range tests {
+		f, err := parseTagFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.matches(tags); got != tt.want {
+			t.Errorf("%s matches = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestServeFreeRooms(t *testing.T) {
+	defer displays.set(displays.all())
+	defer calendars.retain(nil)
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "room.ics")
+	ics := fmt.Sprintf(caldavEvent, "offsite", "20300107T090000Z", "20300107T120000Z")
+	if err := ioutil.WriteFile(path, []byte(ics), 0644); err != nil {
+		t.Fatal(err)
+	}
+	room := Display{ID: "ROOM", Name: "Room", URL: "file://" + path, Timezone: "UTC"}
+	floor := Display{ID: "FLOOR", Name: "Floor", Layout: "floor", Rooms: []string{"ROOM"}, Timezone: "UTC"}
+	displays.set(map[string]Display{room.ID: room, floor.ID: floor})
+	calendars.refresh(room)
+	calendars.refresh(floor)
+
+	tests := []struct {
+		at   string
+		want []string
+	}{
+		{"", []string{"ROOM"}},
+		{"2030-01-07T10:00:00Z", []string{}},
+		{"2030-01-07T13:00:00Z", []string{"ROOM"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		serveFreeRooms(w, httptest.NewRequest("GET", "/api/rooms/free?minutes=30&at="+tt.at, nil))
+		var rooms []freeRoom
+		if err := json.NewDecoder(w.Body).Decode(&rooms); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, r := range rooms {
+			got = append(got, r.Display)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("at %q: got %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"time"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dkit"
+)
+
+// rows of the floor table shrink down to this height, more rooms are split
+// into pages
+var minFloorRow, maxFloorRow = 26.0, 52.0
+
+type FloorRoom struct {
+	Name  string
+	Busy  bool
+	Until time.Time
+	Stale bool
+}
+
+// Floor is the occupancy of the rooms of a floor display.
+type Floor struct {
+	Name       string
+	Date       string
+	Rooms      []FloorRoom
+	Page       int
+	Pages      int
+	LowBattery bool
+	NextChange time.Time
+}
+
+func floorRowsPerPage(profile Profile) int {
+	l := newLayout(profile.size())
+	return int(l.y(260) / l.y(minFloorRow))
+}
+
+// renderDisplay returns what the image of d shows, to compute its ETag, and
+// how to render it.
+func renderDisplay(d Display, c clock, schedule Schedule, page int, profile Profile, colors Colors) (interface{}, func() *image.RGBA) {
+	if d.Layout == "floor" {
+		floor := buildFloor(d, c, schedule, page, floorRowsPerPage(profile))
+		floor.LowBattery = schedule.LowBattery
+		return floor, func() *image.RGBA { return renderFloor(floor, profile, colors) }
+	}
+	return schedule, func() *image.RGBA { return renderClock(schedule, profile, colors) }
+}
+
+// floorRooms returns the occupancy of the rooms of d and when the first of
+// them changes.
+func floorRooms(d Display, c clock) ([]FloorRoom, time.Time) {
+	var rooms []FloorRoom
+	var nextChange time.Time
+	for _, id := range d.Rooms {
+		room, ok := displays.get(id)
+		if !ok {
+			log.Println("floor", d.ID, "room not found", id)
+			continue
+		}
+		if room.Layout == "floor" {
+			log.Println("floor", d.ID, "room is a floor", id)
+			continue
+		}
+		schedule, err := displaySchedule(room, c)
+		if err != nil {
+			log.Println("floor", d.ID, "room", id, err)
+			continue
+		}
+		r := FloorRoom{Name: room.Name, Busy: schedule.Blocked, Stale: schedule.StaleSince != ""}
+		if r.Busy {
+			r.Until = schedule.BusyUntil
+		} else {
+			r.Until = schedule.FreeUntil
+		}
+		rooms = append(rooms, r)
+		if next := schedule.NextChange; !next.IsZero() && (nextChange.IsZero() || next.Before(nextChange)) {
+			nextChange = next
+		}
+	}
+	return rooms, nextChange
+}
+
+// floorSchedule is the schedule of a floor display: its rooms, and when it
+// has to poll again.
+func floorSchedule(d Display, c clock) Schedule {
+	rooms, nextChange := floorRooms(d, c)
+	return Schedule{Name: d.Name, NextChange: nextChange, Rooms: rooms}
+}
+
+// buildFloor collects the rooms of the floor schedule of d for page, counted
+// from 1.
+func buildFloor(d Display, c clock, schedule Schedule, page int, perPage int) Floor {
+	floor := Floor{Name: d.Name, NextChange: schedule.NextChange}
+	if tz, err := loadLocation(d.Timezone); err == nil {
+		floor.Date = c.Now().In(tz).Format("02.01.2006")
+	}
+	rooms := schedule.Rooms
+
+	floor.Pages = (len(rooms) + perPage - 1) / perPage
+	if floor.Pages == 0 {
+		floor.Pages = 1
+	}
+	floor.Page = page
+	if floor.Page < 1 || floor.Page > floor.Pages {
+		floor.Page = 1
+	}
+	start := (floor.Page - 1) * perPage
+	end := start + perPage
+	if end > len(rooms) {
+		end = len(rooms)
+	}
+	floor.Rooms = rooms[start:end]
+	return floor
+}
+
+// renderFloor draws one row per room with a busy marker and until when the
+// room stays busy or free.
+func renderFloor(floor Floor, profile Profile, colors Colors) *image.RGBA {
+	width, height := profile.size()
+	l := newLayout(width, height)
+	dest := image.NewRGBA(image.Rect(0, 0, width, height))
+	gc := draw2dimg.NewGraphicContext(dest)
+	gc.SetDPI(profile.DPI)
+	draw2dkit.Rectangle(gc, 0, 0, l.width, l.height)
+	gc.SetFillColor(colors.Background)
+	gc.SetStrokeColor(colors.Background)
+	gc.FillStroke()
+	gc.SetFillColor(colors.Foreground)
+	gc.SetStrokeColor(colors.Foreground)
+	gc.FontCache.Store(draw2d.FontData{Name: "roboto"}, regular)
+	gc.FontCache.Store(draw2d.FontData{Name: "roboto-bold"}, bold)
+	gc.SetFontData(draw2d.FontData{Name: "roboto-bold"})
+	gc.SetFontSize(l.font(30))
+	gc.FillStringAt(floor.Name, l.x(85), l.y(70))
+	gc.SetFontSize(l.font(20))
+	gc.FillStringAt(floor.Date, l.x(425), l.y(60))
+	if floor.LowBattery {
+		drawLowBattery(gc, l, colors)
+	}
+
+	border := l.x(85)
+	widthEnd := l.width - border
+	top := l.y(100)
+	rowHeight := math.Min(l.y(maxFloorRow), l.y(260)/math.Max(1, float64(len(floor.Rooms))))
+	marker := rowHeight * 0.6
+	gc.SetFontSize(math.Min(l.font(20), rowHeight*0.45))
+
+	gc.SetLineWidth(1)
+	for i, room := range floor.Rooms {
+		y0 := top + rowHeight*float64(i)
+		my := y0 + (rowHeight-marker)/2
+		if room.Busy {
+			gc.SetFillColor(colors.Accent)
+			gc.SetStrokeColor(colors.Accent)
+			draw2dkit.RoundedRectangle(gc, border, my, border+marker, my+marker, marker/4, marker/4)
+			gc.FillStroke()
+		} else {
+			gc.SetLineWidth(2)
+			gc.SetStrokeColor(colors.Foreground)
+			draw2dkit.RoundedRectangle(gc, border+1, my+1, border+marker-1, my+marker-1, marker/4, marker/4)
+			gc.Stroke()
+		}
+
+		status := "free"
+		if room.Busy {
+			status = "busy"
+		}
+		if !room.Until.IsZero() {
+			status += " until " + room.Until.Format("15:04")
+		}
+		if room.Stale {
+			status += " (stale)"
+		}
+		gc.SetFillColor(colors.Foreground)
+		baseline := y0 + rowHeight/2 + gc.Current.FontSize*float64(profile.DPI)/72*0.35
+		left, _, right, _ := gc.GetStringBounds(status)
+		gc.FillStringAt(status, widthEnd-(right-left), baseline)
+		nameWidth := widthEnd - (right - left) - (border + marker + 10) - 10
+		gc.FillStringAt(fitString(gc, room.Name, "", nameWidth), border+marker+10, baseline)
+
+		gc.SetLineWidth(1)
+		gc.SetStrokeColor(colors.Foreground)
+		gc.MoveTo(border, y0+rowHeight)
+		gc.LineTo(widthEnd, y0+rowHeight)
+		gc.Stroke()
+	}
+
+	if floor.Pages > 1 {
+		gc.SetFontData(draw2d.FontData{Name: "roboto"})
+		gc.SetFontSize(l.font(12))
+		page := fmt.Sprintf("%d/%d", floor.Page, floor.Pages)
+		left, _, right, _ := gc.GetStringBounds(page)
+		gc.FillStringAt(page, widthEnd-(right-left), l.y(378))
+	}
+
+	return rotate(dest, profile.Rotation)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildFloor(t *testing.T) {
+	defer displays.set(displays.all())
+	at := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	floor := Display{ID: "F", Name: "Floor 3", Layout: "floor", Rooms: []string{"R1", "R2", "R3", "F"}, Timezone: "UTC"}
+	ds := map[string]Display{"F": floor}
+	for _, id := range []string{"R1", "R2", "R3"} {
+		ds[id] = Display{ID: id, Name: "Room " + id, Timezone: "UTC"}
+		setCalendar(id, at)
+	}
+	defer calendars.retain(nil)
+	setCalendar("R1", at, testEvent(at.Add(-time.Hour), at.Add(time.Hour)))
+	displays.set(ds)
+
+	schedule, err := displaySchedule(floor, fixedClock(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule.Rooms) != 3 {
+		t.Fatalf("got %d rooms, want 3 without the floor itself", len(schedule.Rooms))
+	}
+	if r := schedule.Rooms[0]; !r.Busy || !r.Until.Equal(at.Add(time.Hour)) {
+		t.Errorf("room 1: got %+v", r)
+	}
+
+	tests := []struct {
+		page, wantPage, wantRooms int
+	}{
+		{1, 1, 2},
+		{2, 2, 1},
+		{5, 1, 2},
+	}
+	for _, tt := range tests {
+		f := buildFloor(floor, fixedClock(at), schedule, tt.page, 2)
+		if f.Pages != 2 || f.Page != tt.wantPage || len(f.Rooms) != tt.wantRooms {
+			t.Errorf("page %d: got page %d/%d with %d rooms", tt.page, f.Page, f.Pages, len(f.Rooms))
+		}
+	}
+}
+
+func TestLoadDisplaysFloorRooms(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	room := `{"id": "r1", "url": "file:///dev/null", "timezone": "UTC"}`
+	tests := []struct {
+		config string
+		ok     bool
+	}{
+		{`{"displays": [` + room + `, {"id": "f", "layout": "floor", "rooms": ["r1"], "timezone": "UTC"}]}`, true},
+		{`{"displays": [{"id": "f", "layout": "floor", "rooms": ["f"], "timezone": "UTC"}]}`, false},
+		{`{"displays": [` + room + `, {"id": "f", "layout": "floor", "rooms": ["r1"], "timezone": "UTC"}, {"id": "g", "layout": "floor", "rooms": ["f"], "timezone": "UTC"}]}`, false},
+		{`{"displays": [{"id": "f", "layout": "floor", "rooms": ["missing"], "timezone": "UTC"}]}`, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "config.json")
+		if err := ioutil.WriteFile(path, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadDisplays(path); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.config, err)
+		}
+	}
+}
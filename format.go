@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/url"
+
+	"golang.org/x/image/bmp"
+)
+
+var contentTypes = map[string]string{
+	"bmp":   "image/bmp",
+	"png":   "image/png",
+	"pbm":   "image/x-portable-bitmap",
+	"gray4": "image/x-portable-graymap",
+	"epd":   "application/octet-stream",
+}
+
+var paletteGray4 = color.Palette{
+	color.Gray{0x00},
+	color.Gray{0x55},
+	color.Gray{0xaa},
+	color.Gray{0xff},
+}
+
+// renderOptions selects how a rendered image is encoded. The zero value is a
+// BMP without dithering.
+type renderOptions struct {
+	Format string
+	Dither string
+	EPD    epdOptions
+}
+
+func parseRenderOptions(q url.Values) (renderOptions, error) {
+	opts := renderOptions{Format: q.Get("format"), Dither: q.Get("dither")}
+	if opts.Format == "" {
+		opts.Format = "bmp"
+	}
+	if _, ok := contentTypes[opts.Format]; !ok {
+		return opts, fmt.Errorf("unknown format %q", opts.Format)
+	}
+	if opts.Dither == "" {
+		opts.Dither = "fs"
+	}
+	if !dithers[opts.Dither] {
+		return opts, fmt.Errorf("unknown dither %q", opts.Dither)
+	}
+	if opts.Format == "epd" {
+		epd, err := parseEPDOptions(q)
+		if err != nil {
+			return opts, err
+		}
+		opts.EPD = epd
+	}
+	return opts, nil
+}
+
+func (o renderOptions) contentType() string {
+	if t, ok := contentTypes[o.Format]; ok {
+		return t
+	}
+	return contentTypes["bmp"]
+}
+
+// encode quantizes img to the colors the target can show and writes it in
+// the selected format.
+func (o renderOptions) encode(w io.Writer, img image.Image, profile Profile, colors Colors) error {
+	switch o.Format {
+	case "png":
+		return png.Encode(w, quantize(img, profile.Palette, o.Dither))
+	case "pbm":
+		return encodePBM(w, quantize(img, paletteBW, o.Dither))
+	case "gray4":
+		return encodeGray4(w, quantize(img, paletteGray4, o.Dither))
+	case "epd":
+		palette := color.Palette{colors.Background, colors.Foreground, colors.Accent}
+		return encodeEPD(w, quantize(img, palette, o.Dither), colors, o.EPD)
+	default:
+		// keep 24 bit BMPs, only the colors are reduced
+		dest := image.NewRGBA(img.Bounds())
+		draw.Draw(dest, dest.Bounds(), quantize(img, profile.Palette, o.Dither), image.Point{}, draw.Src)
+		return bmp.Encode(w, dest)
+	}
+}
+
+// encodePBM writes a binary PBM, img must use paletteBW.
+func encodePBM(w io.Writer, img *image.Paletted) error {
+	b := img.Bounds()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P4\n%d %d\n", b.Dx(), b.Dy())
+	row := make([]byte, (b.Dx()+7)/8)
+	for y := 0; y < b.Dy(); y++ {
+		for i := range row {
+			row[i] = 0
+		}
+		for x := 0; x < b.Dx(); x++ {
+			// index 1 is black, which is 1 in PBM
+			if img.Pix[y*img.Stride+x] == 1 {
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+		bw.Write(row)
+	}
+	return bw.Flush()
+}
+
+// encodeGray4 writes a binary PGM with 4 levels, img must use paletteGray4.
+func encodeGray4(w io.Writer, img *image.Paletted) error {
+	b := img.Bounds()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P5\n%d %d\n3\n", b.Dx(), b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		bw.Write(img.Pix[y*img.Stride : y*img.Stride+b.Dx()])
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden images in testdata/golden")
+
+// differing pixels are those with a channel off by more than goldenDelta,
+// up to goldenTolerance of them are accepted for font rasterization
+var goldenDelta, goldenTolerance = 64, 0.002
+
+// goldenBlock blocks the slots from to to, counted from the first row.
+type goldenBlock struct {
+	from, to int
+	pattern  string
+}
+
+type goldenCase struct {
+	schedule Schedule
+	profile  Profile
+}
+
+// goldenSchedule returns four hours from 08:00 with blocks.
+func goldenSchedule(blocks ...goldenBlock) Schedule {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	s := Schedule{
+		Name:       "Meeting Room 1",
+		Date:       "16.10.2026",
+		Start:      start,
+		SlotLength: 5 * time.Minute,
+		BlockInfos: newBlockInfos(4, 12),
+	}
+	for i := range s.BlockInfos {
+		s.BlockInfos[i].Time = start.Add(time.Duration(i) * time.Hour).Format("15:04")
+	}
+	for _, b := range blocks {
+		for i := b.from; i < b.to; i++ {
+			s.BlockInfos[i/12].Blocked[i%12] = true
+			s.BlockInfos[i/12].Patterns[i%12] = b.pattern
+		}
+	}
+	return s
+}
+
+func goldenCases() map[string]goldenCase {
+	at := func(h, m int) time.Time { return time.Date(2026, 10, 16, h, m, 0, 0, time.UTC) }
+
+	free := goldenSchedule(goldenBlock{18, 24, "solid"}, goldenBlock{30, 42, "solid"})
+	free.Next = &Meeting{Summary: "Design review", Start: at(9, 30), End: at(10, 0)}
+	free.FreeUntil = at(9, 30)
+
+	busy := goldenSchedule(goldenBlock{0, 9, "solid"}, goldenBlock{9, 15, "hatched"}, goldenBlock{24, 30, "outline"}, goldenBlock{36, 48, "solid"})
+	busy.Blocked = true
+	busy.Current = &Meeting{Summary: "Quarterly planning with a rather long title", Organizer: "Jane Doe", Start: at(8, 0), End: at(8, 45)}
+	busy.Next = &Meeting{Summary: "Retro", Start: at(10, 0), End: at(10, 30)}
+	busy.BusyUntil = at(9, 15)
+
+	stale := goldenSchedule(goldenBlock{12, 20, "solid"})
+	stale.StaleSince = "07:55"
+	stale.LowBattery = true
+	stale.Next = &Meeting{Summary: "Offsite", Start: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)}
+
+	portrait := defaultProfile
+	portrait.Rotation = 90
+
+	return map[string]goldenCase{
+		"free":     {free, defaultProfile},
+		"busy":     {busy, defaultProfile},
+		"stale":    {stale, defaultProfile},
+		"epd75v2":  {busy, profiles["epd75v2"]},
+		"epd42":    {busy, profiles["epd42"]},
+		"portrait": {free, portrait},
+	}
+}
+
+// diffImage compares got to want. It returns the number of differing pixels
+// and an image with them in red over a faded copy of want.
+func diffImage(got, want image.Image) (int, *image.RGBA) {
+	b := want.Bounds()
+	diff := image.NewRGBA(b)
+	n := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, _ := got.At(x, y).RGBA()
+			r2, g2, b2, _ := want.At(x, y).RGBA()
+			if channelDelta(r1, r2) > goldenDelta || channelDelta(g1, g2) > goldenDelta || channelDelta(b1, b2) > goldenDelta {
+				n++
+				diff.Set(x, y, color.RGBA{0xff, 0x00, 0x00, 0xff})
+				continue
+			}
+			gray := uint8(0xc0 + (r2>>8+g2>>8+b2>>8)/3/4)
+			diff.Set(x, y, color.RGBA{gray, gray, gray, 0xff})
+		}
+	}
+	return n, diff
+}
+
+func channelDelta(a, b uint32) int {
+	d := int(a>>8) - int(b>>8)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func writePNG(path string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "golden")
+	for name, g := range goldenCases() {
+		var buf bytes.Buffer
+		if err := drawClock(g.schedule, g.profile, g.profile.colors(defaultColors), renderOptions{Format: "png"}, &buf); err != nil {
+			t.Fatal(err)
+		}
+		got, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name+".png")
+		diffPath := filepath.Join(dir, name+".diff.png")
+		if *update {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := writePNG(path, got); err != nil {
+				t.Fatal(err)
+			}
+			os.Remove(diffPath)
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			t.Errorf("%s: %v, run go test -run TestGolden -update", name, err)
+			continue
+		}
+		want, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Bounds() != want.Bounds() {
+			t.Errorf("%s: got size %v, want %v", name, got.Bounds().Size(), want.Bounds().Size())
+			continue
+		}
+		n, diff := diffImage(got, want)
+		size := want.Bounds().Dx() * want.Bounds().Dy()
+		if float64(n) > goldenTolerance*float64(size) {
+			if err := writePNG(diffPath, diff); err != nil {
+				t.Fatal(err)
+			}
+			t.Errorf("%s: %d of %d pixels differ, see %s", name, n, size, diffPath)
+			continue
+		}
+		os.Remove(diffPath)
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os/signal"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/llgcode/draw2d/draw2dimg"
@@ -20,16 +24,14 @@ import (
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
 	"github.com/llgcode/draw2d"
-	"golang.org/x/image/bmp"
+	"math"
 	"math/rand"
 	"os"
-	"regexp"
-	"strings"
 )
 
 func withLogging(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("ts=%s url=%s remoteAddr=%s", time.Now(), r.RequestURI, r.RemoteAddr)
+		log.Printf("ts=%s url=%s remoteAddr=%s", time.Now(), redactURL(r.RequestURI), r.RemoteAddr)
 		next.ServeHTTP(w, r)
 	}
 }
@@ -51,156 +53,331 @@ var red = color.RGBA{0xff, 0x00, 0x00, 0xff}
 var black = color.RGBA{0x00, 0x00, 0x00, 0xff}
 var white = color.RGBA{0xff, 0xff, 0xff, 0xff}
 
-var width, height = 640, 384
-var fwidth, fheight = float64(width), float64(height)
-
 type BlockInfo struct {
-	Time    string
-	Blocked [12]bool
+	Time     string
+	Blocked  []bool
+	Patterns []string
+}
+
+// Meeting is an event shown by name on the display.
+type Meeting struct {
+	Summary   string
+	Organizer string
+	Start     time.Time
+	End       time.Time
 }
 
 type Schedule struct {
 	Name       string
 	Date       string
+	StaleSince string
 	Blocked    bool
-	BlockInfos [4]BlockInfo
+	Current    *Meeting
+	Next       *Meeting
+	FreeUntil  time.Time
+	BusyUntil  time.Time
+	NextChange time.Time
+	LowBattery bool
+	Start      time.Time
+	SlotLength time.Duration
+	BlockInfos []BlockInfo
+	Rooms      []FloorRoom // of floor displays
 }
 
-func init() {
-	ics.RepeatRuleApply = true
-	ics.MaxRepeats = 100
+// newBlockInfos returns hours empty rows of slotsPerHour blocks each.
+func newBlockInfos(hours, slotsPerHour int) []BlockInfo {
+	infos := make([]BlockInfo, hours)
+	for i := range infos {
+		infos[i].Blocked = make([]bool, slotsPerHour)
+		infos[i].Patterns = make([]string, slotsPerHour)
+	}
+	return infos
 }
 
-func buildSchedule(url, timezone, overrideTimezone, name string) (schedule Schedule, err error) {
-	schedule = Schedule{}
-
-	response, err := http.Get(url)
-	if err != nil {
-		log.Println("err", err)
-		return schedule, err
+// newMeeting returns the meeting of e with its times in loc. Private displays
+// only show "Busy".
+func newMeeting(e *calendarEvent, loc *time.Location, private bool) *Meeting {
+	m := &Meeting{
+		Summary: e.GetSummary(),
+		Start:   e.GetStart().In(loc),
+		End:     e.GetEnd().In(loc),
 	}
-	// close the response body
-	defer response.Body.Close()
-
-	icsBytes, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		log.Println("err", err)
-		return schedule, err
+	if o := e.GetOrganizer(); o != nil {
+		m.Organizer = o.GetName()
+		if m.Organizer == "" {
+			m.Organizer = o.GetEmail()
+		}
 	}
-
-	calendar, _, err := ics.ParseICalContent(string(icsBytes), url)
-	if err != nil {
-		log.Println("err", err)
-		return schedule, err
+	if private {
+		m.Summary, m.Organizer = "Busy", ""
 	}
-	schedule.Name = name
+	return m
+}
 
-	// print calendar info
-	//log.Println(calendar.GetName(), calendar.GetDesc())
+func init() {
+	ics.RepeatRuleApply = true
+	ics.MaxRepeats = 100
+}
+
+// buildSchedule returns the schedule of d at the time of c from events,
+// without any fetching. Rows are consecutive hours from the window start, so
+// days with a DST change show the repeated or skipped hour.
+func buildSchedule(events []calendarEvent, c clock, d Display) (schedule Schedule, err error) {
+	schedule = Schedule{}
+	schedule.Name = d.Name
 
-	ttz, err := time.LoadLocation(timezone)
+	tz, err := loadLocation(d.Timezone)
 	if err != nil {
 		return schedule, err
 	}
+	now := c.Now().In(tz).Truncate(time.Second)
 
-	otz, err := time.LoadLocation(overrideTimezone)
-	if err != nil {
-		otz = ttz
+	schedule.BlockInfos = newBlockInfos(d.Window.hours(), d.Window.slotsPerHour())
+	start := now.Add(-time.Duration(now.Minute())*time.Minute - time.Duration(now.Second())*time.Second)
+	if h, ok := d.Window.startHour(); ok {
+		start = time.Date(now.Year(), now.Month(), now.Day(), h, 0, 0, 0, tz)
 	}
+	slot := time.Hour / time.Duration(d.Window.slotsPerHour())
+	end := start.Add(time.Duration(len(schedule.BlockInfos)) * time.Hour)
 
-	//  get the events for the New Years Eve
-	now := time.Now().In(ttz)
-
-	startBlocker := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, otz)
-	endBlocker := startBlocker.Add(time.Duration(len(schedule.BlockInfos)) * time.Hour).Add(time.Hour)
-	nowForBlock := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), 0, otz)
-
-	//log.Printf("    %s %s \n", startBlocker, endBlocker)
-
-	for i := 0; i < len(schedule.BlockInfos); i++ {
-		schedule.BlockInfos[i].Time = fmt.Sprintf("%02d:00", time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, ttz).Add(time.Duration(i)*time.Hour).Hour())
+	for i := range schedule.BlockInfos {
+		schedule.BlockInfos[i].Time = start.Add(time.Duration(i) * time.Hour).Format("15:04")
 	}
 	schedule.Date = now.Format("02.01.2006")
-
-	for _, event := range calendar.GetEvents() {
-		if event.GetStart().Before(nowForBlock) && event.GetEnd().After(nowForBlock) {
+	schedule.Start = start
+	schedule.SlotLength = slot
+
+	blocksPerHour := d.Window.slotsPerHour()
+	totalBlocks := blocksPerHour * len(schedule.BlockInfos)
+	var current, next *calendarEvent
+	var nextChange time.Time
+	for i := range events {
+		event := &events[i]
+		for _, t := range []time.Time{event.GetStart(), event.GetEnd()} {
+			if t.After(now) && (nextChange.IsZero() || t.Before(nextChange)) {
+				nextChange = t
+			}
+		}
+		// a meeting is current from its start on, when displays wake for it
+		if !event.GetStart().After(now) && event.GetEnd().After(now) {
 			schedule.Blocked = true
-			//log.Printf("blocked - %s %s \n", event.GetStart(), event.GetEnd())
+			if current == nil || event.GetStart().Before(current.GetStart()) {
+				current = event
+			}
+		} else if event.GetStart().After(now) && (next == nil || event.GetStart().Before(next.GetStart())) {
+			next = event
 		}
-		blocksPerHour := len(schedule.BlockInfos[0].Blocked)
-		totalBlocks := blocksPerHour * len(schedule.BlockInfos)
-
-		if event.GetStart().Before(endBlocker) && event.GetEnd().After(startBlocker) {
-			startBlock := (hours(event.GetStart())-hours(startBlocker))*blocksPerHour + (event.GetStart().Minute()*blocksPerHour)/60
-			endBlock := (hours(event.GetEnd())-hours(startBlocker))*blocksPerHour + (event.GetEnd().Minute()*blocksPerHour)/60
 
+		if event.GetStart().Before(end) && event.GetEnd().After(start) {
+			startBlock := int(event.GetStart().Sub(start) / slot)
+			endBlock := int(event.GetEnd().Sub(start) / slot)
 			if startBlock < 0 {
 				startBlock = 0
 			}
+			pattern := d.eventPattern(event)
 			for b := startBlock; b < totalBlocks && b < endBlock; b++ {
-				schedule.BlockInfos[b/blocksPerHour].Blocked[b%blocksPerHour] = true
+				info := &schedule.BlockInfos[b/blocksPerHour]
+				// solid blocks win over categorized ones
+				if !info.Blocked[b%blocksPerHour] || pattern == "solid" {
+					info.Patterns[b%blocksPerHour] = pattern
+				}
+				info.Blocked[b%blocksPerHour] = true
 			}
-
-			//log.Printf("%s %s  %d - %d \n", event.GetStart(), event.GetEnd(), startBlock, endBlock)
 		}
-
+	}
+	if current != nil {
+		schedule.Current = newMeeting(current, tz, d.Private)
+		schedule.BusyUntil = busyUntil(events, current.GetEnd()).In(tz)
+	}
+	if next != nil {
+		schedule.Next = newMeeting(next, tz, d.Private)
+		if !schedule.Blocked {
+			schedule.FreeUntil = schedule.Next.Start
+		}
+	}
+	if !nextChange.IsZero() {
+		schedule.NextChange = nextChange.In(tz)
 	}
 
 	return schedule, nil
 }
-func hours(now time.Time) int {
-	return int(time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, time.UTC).Unix() / 3600)
+
+// busyUntil returns the end of the events that follow each other without a
+// gap from end on.
+func busyUntil(events []calendarEvent, end time.Time) time.Time {
+	for changed := true; changed; {
+		changed = false
+		for i := range events {
+			e := &events[i]
+			if !e.GetStart().After(end) && e.GetEnd().After(end) {
+				end, changed = e.GetEnd(), true
+			}
+		}
+	}
+	return end
 }
 
-func serveClock(w http.ResponseWriter, r *http.Request) {
-	var schedule Schedule
-	var err error
+var displays = newDisplayRegistry(envDisplays())
+var feeds = newFeedFetcher(&http.Client{Timeout: 30 * time.Second})
+var calendars = newCalendarCache(feeds)
+var renders = newRenderCache(64)
+var devices = newDeviceRegistry()
+
+// displaySchedule builds the schedule of d at the time of c from its cached
+// calendar. The calendar is fetched first if it was never refreshed, or just
+// for this schedule if c is outside the cached range.
+func displaySchedule(d Display, c clock) (Schedule, error) {
+	if d.Layout == "floor" {
+		return floorSchedule(d, c), nil
+	}
+	snapshot := calendars.snapshotAt(d, c.Now())
+	if snapshot.Fetched.IsZero() {
+		return Schedule{}, snapshot.Err
+	}
+	schedule, err := buildSchedule(snapshot.Events, c, d)
+	if err != nil {
+		return schedule, err
+	}
+	if snapshot.stale() {
+		tz, _ := loadLocation(d.Timezone)
+		schedule.StaleSince = snapshot.Fetched.In(tz).Format("15:04")
+	}
+	return schedule, nil
+}
+
+// requestSchedule returns the display of the request and its schedule, or a
+// random schedule if the request names no configured display.
+func requestSchedule(r *http.Request, c clock) (Display, Schedule, error) {
 	display := r.URL.Query().Get("display")
 	if display != "" {
 		sanDisplay := sanitize(display)
 		// log.Println("display", display, "sanitized", sanDisplay)
 
-		name := os.Getenv("DISPLAY_" + sanDisplay + "_NAME")
-		url := os.Getenv("DISPLAY_" + sanDisplay + "_URL")
-		tz := os.Getenv("DISPLAY_" + sanDisplay + "_TZ")
-		otz := os.Getenv("DISPLAY_" + sanDisplay + "_OTZ")
-
-		if url != "" && tz != "" {
-			schedule, err = buildSchedule(url, tz, otz, name)
-			if err != nil {
-				log.Println(err)
-				w.WriteHeader(500)
-				return
-			}
-		} else {
-			log.Println("not found", sanDisplay)
+		if d, ok := displays.get(sanDisplay); ok {
+			schedule, err := displaySchedule(d, c)
+			return d, schedule, err
+		}
+		log.Println("not found", sanDisplay)
+	}
+	return Display{}, randomSchedule(int64(c.Now().Minute()), c), nil
+}
+
+func serveClock(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseRenderOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page := 1
+	if s := r.URL.Query().Get("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "invalid page", http.StatusBadRequest)
+			return
 		}
 	}
-	if schedule.Name == "" {
-		schedule = randomSchedule(int64(time.Now().Minute()))
+	c, err := requestClock(r)
+	if err == errAtNotAllowed {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	err = drawClock(schedule, w)
+	d, schedule, err := requestSchedule(r, c)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	// previews at another time are not polls of the display
+	if _, ok := c.(systemClock); ok && d.ID != "" {
+		telemetry := parseTelemetry(r)
+		if telemetry.reported() {
+			devices.report(d.ID, telemetry)
+		} else if last, ok := devices.get(d.ID); ok {
+			telemetry = last
+		}
+		schedule.LowBattery = telemetry.lowBattery(d)
+	}
+	profile := d.profile()
+	colors := profile.colors(d.Colors.withDefaults())
 
+	now := c.Now()
+	w.Header().Set("X-Sleep-Seconds", strconv.Itoa(int(wakeAt(now, schedule, d).Sub(now).Seconds())))
+	content, render := renderDisplay(d, c, schedule, page, profile, colors)
+	etag, err := renderETag(content, profile, colors, opts)
 	if err != nil {
 		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, ok := renders.get(etag)
+	if !ok {
+		var buf bytes.Buffer
+		if err := opts.encode(&buf, render(), profile, colors); err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+		body = buf.Bytes()
+		renders.put(etag, body)
+	}
+	w.Header().Set("Content-Type", opts.contentType())
+	if _, err := w.Write(body); err != nil {
+		log.Println(err)
 	}
 }
 
-var regular = getFont("Regular")
-var bold = getFont("Bold")
+// serveSleep tells a display how long to sleep before it polls /clock again.
+func serveSleep(w http.ResponseWriter, r *http.Request) {
+	d, schedule, err := requestSchedule(r, systemClock{})
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	now := time.Now()
+	wake := wakeAt(now, schedule, d)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(struct {
+		Seconds int       `json:"seconds"`
+		WakeAt  time.Time `json:"wakeAt"`
+	}{int(wake.Sub(now).Seconds()), wake})
+	if err != nil {
+		log.Println(err)
+	}
+}
 
-var notWhitelist = regexp.MustCompile(`[^0-9A-Z]`)
+func serveDevices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(devices.all()); err != nil {
+		log.Println(err)
+	}
+}
 
-func sanitize(s string) string {
-	return notWhitelist.ReplaceAllString(strings.ToUpper(s), "")
+func serveFeedStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(feeds.stats()); err != nil {
+		log.Println(err)
+	}
 }
 
-func randomSchedule(seed int64) Schedule {
+var regular = getFont("Regular")
+var bold = getFont("Bold")
+
+func randomSchedule(seed int64, c clock) Schedule {
 	rand.Seed(seed)
 	schedule := Schedule{
-		Blocked: rand.Float32() > 0.5,
-		Name:    "Random Room",
-		Date:    time.Now().Format("2006-01-02"),
+		Blocked:    rand.Float32() > 0.5,
+		Name:       "Random Room",
+		Date:       c.Now().Format("2006-01-02"),
+		BlockInfos: newBlockInfos(defaultHours, defaultSlotsPerHour),
 	}
 	blocked := schedule.Blocked
 	for i := 0; i < len(schedule.BlockInfos); i++ {
@@ -210,53 +387,71 @@ func randomSchedule(seed int64) Schedule {
 			}
 			schedule.BlockInfos[i].Blocked[j] = blocked
 		}
-		schedule.BlockInfos[i].Time = fmt.Sprintf("%02d:00", time.Now().Hour()+i)
+		schedule.BlockInfos[i].Time = fmt.Sprintf("%02d:00", c.Now().Hour()+i)
 	}
 	return schedule
 }
 
-func drawClock(schedule Schedule, w io.Writer) error {
+func drawClock(schedule Schedule, profile Profile, colors Colors, opts renderOptions, w io.Writer) error {
+	// Save to file
+	return opts.encode(w, renderClock(schedule, profile, colors), profile, colors)
+}
+
+// renderClock draws schedule for profile, rotated as the panel is mounted.
+func renderClock(schedule Schedule, profile Profile, colors Colors) *image.RGBA {
+	width, height := profile.size()
+	l := newLayout(width, height)
 	dest := image.NewRGBA(image.Rect(0, 0, width, height))
 	gc := draw2dimg.NewGraphicContext(dest)
-	draw2dkit.Rectangle(gc, 0, 0, fwidth, fheight)
-	gc.SetFillColor(white)
+	gc.SetDPI(profile.DPI)
+	draw2dkit.Rectangle(gc, 0, 0, l.width, l.height)
+	gc.SetFillColor(colors.Background)
+	gc.SetStrokeColor(colors.Background)
 	gc.FillStroke()
 	// Set some properties
-	gc.SetFillColor(black)
-	gc.SetStrokeColor(black)
+	gc.SetFillColor(colors.Foreground)
+	gc.SetStrokeColor(colors.Foreground)
 	gc.SetLineWidth(5)
 	gc.FontCache.Store(draw2d.FontData{Name: "roboto"}, regular)
 	gc.FontCache.Store(draw2d.FontData{Name: "roboto-bold"}, bold)
 	gc.SetFontData(draw2d.FontData{Name: "roboto-bold"})
 	// Clock
-	gc.SetFontSize(30)
-	gc.FillStringAt(schedule.Name, 85, 70)
-	gc.SetFontSize(20)
-	gc.FillStringAt(schedule.Date, 425, 60)
-	drawQuarters(gc, schedule)
+	gc.SetFontSize(l.font(30))
+	gc.FillStringAt(schedule.Name, l.x(85), l.y(70))
+	gc.SetFontSize(l.font(20))
+	gc.FillStringAt(schedule.Date, l.x(425), l.y(60))
+	if schedule.StaleSince != "" {
+		gc.SetFontData(draw2d.FontData{Name: "roboto"})
+		gc.SetFontSize(l.font(12))
+		gc.FillStringAt("stale since "+schedule.StaleSince, l.x(425), l.y(80))
+	}
+	if schedule.LowBattery {
+		drawLowBattery(gc, l, colors)
+	}
+	drawQuarters(gc, l, schedule, colors)
+	drawMeetings(gc, l, schedule, colors)
 
-	// Save to file
-	return bmp.Encode(w, dest)
+	return rotate(dest, profile.Rotation)
 }
 
-func drawQuarters(gc *draw2dimg.GraphicContext, schedule Schedule) {
+func drawQuarters(gc *draw2dimg.GraphicContext, l layout, schedule Schedule, colors Colors) {
 
 	lines := len(schedule.BlockInfos)
-	startHeight, heightLine := 100.0, 50.0
-	heightEnd := startHeight + heightLine*float64(lines)
-	border := 85.0
-	widthEnd := fwidth - border
-	middleLine := 150.0
+	startHeight, heightEnd := l.y(100), l.y(300)
+	heightLine := (heightEnd - startHeight) / float64(lines)
+	border := l.x(85)
+	widthEnd := l.width - border
+	middleLine := l.x(150)
 
 	if schedule.Blocked {
-		gc.SetStrokeColor(red)
-		gc.SetFillColor(red)
-		draw2dkit.Rectangle(gc, border, heightEnd+25, widthEnd+2, heightEnd+50)
+		gc.SetStrokeColor(colors.Accent)
+		gc.SetFillColor(colors.Accent)
+		draw2dkit.Rectangle(gc, border, heightEnd+l.y(25), widthEnd+2, heightEnd+l.y(50))
 		gc.FillStroke()
 
 	}
-	gc.SetStrokeColor(black)
-	gc.SetFillColor(black)
+	gc.SetStrokeColor(colors.Foreground)
+	gc.SetFillColor(colors.Foreground)
 
 	gc.SetLineWidth(2)
 	gc.MoveTo(border, startHeight)
@@ -275,13 +470,13 @@ func drawQuarters(gc *draw2dimg.GraphicContext, schedule Schedule) {
 	gc.Stroke()
 
 	gc.SetFontData(draw2d.FontData{Name: "roboto-bold"})
-	gc.SetFontSize(16)
+	gc.SetFontSize(math.Min(l.font(16), heightLine/2))
 	for i := 1; i <= lines; i++ {
-		gc.FillStringAt(schedule.BlockInfos[i-1].Time, border+5, startHeight+heightLine*float64(i)-17)
+		gc.FillStringAt(schedule.BlockInfos[i-1].Time, border+5, startHeight+heightLine*float64(i)-heightLine*0.34)
 	}
 
 	gc.SetFontData(draw2d.FontData{Name: "roboto-bold"})
-	gc.SetFontSize(13)
+	gc.SetFontSize(l.font(13))
 	for i := 0; i < 4; i++ {
 		colWidth := (widthEnd - middleLine) / float64(4)
 		gc.FillStringAt(fmt.Sprintf(":%02d", 15*i), middleLine+colWidth*float64(i), startHeight-4)
@@ -291,20 +486,160 @@ func drawQuarters(gc *draw2dimg.GraphicContext, schedule Schedule) {
 	for i := 0; i < lines; i++ {
 		cols := len(schedule.BlockInfos[i].Blocked)
 		colWidth := (widthEnd - middleLine) / float64(cols)
+		padX, padY := math.Min(4, colWidth*0.12), math.Min(4, heightLine*0.08)
+		radius := math.Min(5, math.Min(padX, padY)*1.25)
 		for j := 0; j < cols; j++ {
-			if schedule.BlockInfos[i].Blocked[j] {
-				draw2dkit.RoundedRectangle(gc,
-					middleLine+colWidth*float64(j)+4, startHeight+heightLine*float64(i)+4,
-					middleLine+colWidth*float64(j+1)-4, startHeight+heightLine*float64(i+1)-4,
-					5, 5)
+			if !schedule.BlockInfos[i].Blocked[j] {
+				continue
+			}
+			x0, y0 := middleLine+colWidth*float64(j)+padX, startHeight+heightLine*float64(i)+padY
+			x1, y1 := middleLine+colWidth*float64(j+1)-padX, startHeight+heightLine*float64(i+1)-padY
+			switch schedule.BlockInfos[i].Patterns[j] {
+			case "hatched":
+				gc.SetLineWidth(1)
+				draw2dkit.Rectangle(gc, x0, y0, x1, y1)
+				hatch(gc, x0, y0, x1, y1, 6)
+				gc.Stroke()
+			case "outline":
+				gc.SetLineWidth(2)
+				draw2dkit.RoundedRectangle(gc, x0+1, y0+1, x1-1, y1-1, radius, radius)
+				gc.Stroke()
+			default:
+				gc.SetLineWidth(2)
+				draw2dkit.RoundedRectangle(gc, x0, y0, x1, y1, radius, radius)
+				gc.FillStroke()
 			}
 		}
 	}
-	gc.FillStroke()
+}
+
+// drawMeetings writes the current meeting into the busy bar and the next
+// meeting below it.
+func drawMeetings(gc *draw2dimg.GraphicContext, l layout, schedule Schedule, colors Colors) {
+	border := l.x(85)
+	maxWidth := l.width - 2*border - 16
+
+	gc.SetFontData(draw2d.FontData{Name: "roboto-bold"})
+	gc.SetFontSize(l.font(14))
+	if m := schedule.Current; m != nil {
+		until := "until " + m.End.Format("15:04")
+		if m.Organizer != "" {
+			until = m.Organizer + ", " + until
+		}
+		gc.SetFillColor(colors.Background)
+		gc.FillStringAt(fitString(gc, "Now: "+m.Summary, " ("+until+")", maxWidth), border+8, l.y(343))
+	}
+	if m := schedule.Next; m != nil {
+		at := m.Start.Format("15:04")
+		if m.Start.Format("02.01.2006") != schedule.Date {
+			at = m.Start.Format("Mon 15:04")
+		}
+		gc.SetFillColor(colors.Foreground)
+		gc.FillStringAt(fitString(gc, "Next: "+m.Summary, " at "+at, maxWidth), border+8, l.y(372))
+	}
+}
+
+// drawLowBattery draws an almost empty battery above the date.
+func drawLowBattery(gc *draw2dimg.GraphicContext, l layout, colors Colors) {
+	x1, y0 := l.width-l.x(85), l.y(14)
+	w, h := l.font(30), l.font(14)
+	x0 := x1 - w
+
+	gc.SetLineWidth(2)
+	gc.SetStrokeColor(colors.Foreground)
+	draw2dkit.Rectangle(gc, x0, y0, x1-3, y0+h)
+	gc.Stroke()
+	gc.SetFillColor(colors.Foreground)
+	draw2dkit.Rectangle(gc, x1-3, y0+h/3, x1, y0+2*h/3)
+	gc.Fill()
+	gc.SetFillColor(colors.Accent)
+	draw2dkit.Rectangle(gc, x0+3, y0+3, x0+3+w/5, y0+h-3)
+	gc.Fill()
+	gc.SetFillColor(colors.Foreground)
+}
+
+// fitString shortens s so that s+suffix is at most maxWidth wide.
+func fitString(gc *draw2dimg.GraphicContext, s, suffix string, maxWidth float64) string {
+	for r := []rune(s); ; r = r[:len(r)-1] {
+		text := string(r)
+		if len(r) < len([]rune(s)) {
+			text += "..."
+		}
+		left, _, right, _ := gc.GetStringBounds(text + suffix)
+		if right-left <= maxWidth || len(r) == 0 {
+			return text + suffix
+		}
+	}
+}
+
+// hatch adds diagonal lines every spacing pixels inside the rectangle to the
+// current path.
+func hatch(gc *draw2dimg.GraphicContext, x0, y0, x1, y1, spacing float64) {
+	w, h := x1-x0, y1-y0
+	for k := spacing; k < w+h; k += spacing {
+		gc.MoveTo(x0+math.Min(k, w), y0+math.Max(0, k-w))
+		gc.LineTo(x0+math.Max(0, k-h), y0+math.Min(k, h))
+	}
+}
+
+// reloadDisplays replaces the configured displays and restarts the calendar
+// refreshers. The previous displays are kept if the config can't be loaded.
+func reloadDisplays(ctx context.Context, configPath string) func() {
+	var mu sync.Mutex
+	cancel := func() {}
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		ds, err := loadDisplays(configPath)
+		if err != nil {
+			log.Println("config not loaded", err)
+			return
+		}
+		cancel()
+		var refreshCtx context.Context
+		refreshCtx, cancel = context.WithCancel(ctx)
+		displays.set(ds)
+		calendars.retain(ds)
+		calendars.start(refreshCtx, ds, refreshInterval())
+		log.Printf("loaded %d displays", len(ds))
+	}
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		err := runRender(os.Args[2:], os.Stdout, os.Stderr)
+		if err != nil && err != flag.ErrHelp {
+			log.Println(err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configPath := os.Getenv("CONFIG")
+	if configPath != "" {
+		if _, err := loadDisplays(configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	reload := reloadDisplays(ctx, configPath)
+	reload()
+	if configPath != "" {
+		go watchConfig(ctx, configPath, 5*time.Second, reload)
+	}
+
 	http.HandleFunc("/clock", withLogging(serveClock))
+	http.HandleFunc("/sleep", withLogging(serveSleep))
+	http.HandleFunc("/devices", withLogging(serveDevices))
+	http.HandleFunc("/feeds", withLogging(serveFeedStats))
+	http.HandleFunc("/api/displays/", withLogging(serveAPI))
+	http.HandleFunc("/api/rooms/free", withLogging(serveFreeRooms))
+	http.HandleFunc("/book", withLogging(serveBook))
+	http.HandleFunc("/admin", withLogging(withAdminAuth(serveAdmin)))
+	http.HandleFunc("/admin/preview", withLogging(withAdminAuth(serveAdminPreview)))
+	http.HandleFunc("/admin/refresh", withLogging(withAdminAuth(serveAdminRefresh)))
 
 	addr := ""
 	port := os.Getenv("PORT")
@@ -327,7 +662,8 @@ func main() {
 	}()
 
 	<-stop
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	server.Shutdown(ctx)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	server.Shutdown(shutdownCtx)
 	log.Println("Server gracefully stopped")
 }
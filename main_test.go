@@ -3,21 +3,128 @@ package main
 import (
 	"bytes"
 	"testing"
+	"time"
 )
 
 func Benchmark_drawClock(b *testing.B) {
 
 	var buf bytes.Buffer
 
-	schedule1 := randomSchedule(3)
-	schedule2 := randomSchedule(4)
-	schedule3 := randomSchedule(5)
+	schedule1 := randomSchedule(3, systemClock{})
+	schedule2 := randomSchedule(4, systemClock{})
+	schedule3 := randomSchedule(5, systemClock{})
 	for n := 0; n < b.N; n++ {
 
-		_ = drawClock(schedule1, &buf)
-		_ = drawClock(schedule2, &buf)
-		_ = drawClock(schedule3, &buf)
+		_ = drawClock(schedule1, defaultProfile, defaultColors, renderOptions{}, &buf)
+		_ = drawClock(schedule2, defaultProfile, defaultColors, renderOptions{}, &buf)
+		_ = drawClock(schedule3, defaultProfile, defaultColors, renderOptions{}, &buf)
 
 		buf.Reset()
 	}
 }
+
+func TestBuildSchedule(t *testing.T) {
+	base := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	standup := testEvent(base, base.Add(15*time.Minute))
+	standup.SetSummary("Standup")
+	review := testEvent(base.Add(90*time.Minute), base.Add(2*time.Hour))
+	review.SetSummary("Review")
+	events, err := memorySource{standup.Event, review.Event}.events(base.Add(-24*time.Hour), base.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar := []calendarEvent{{events[0], ""}, {events[1], "optional"}}
+	d := Display{Name: "Room", Timezone: "UTC", Window: Window{Start: "08:00"}}
+
+	schedule, err := buildSchedule(calendar, fixedClock(base.Add(5*time.Minute)), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schedule.Blocked || schedule.Current == nil || schedule.Current.Summary != "Standup" {
+		t.Errorf("current: got %+v", schedule.Current)
+	}
+	if !schedule.BusyUntil.Equal(base.Add(15 * time.Minute)) {
+		t.Errorf("busy until: got %v", schedule.BusyUntil)
+	}
+	if schedule.Next == nil || schedule.Next.Summary != "Review" {
+		t.Errorf("next: got %+v", schedule.Next)
+	}
+	if !schedule.NextChange.Equal(base.Add(15 * time.Minute)) {
+		t.Errorf("next change: got %v", schedule.NextChange)
+	}
+	if got := schedule.BlockInfos[0].Time; got != "08:00" {
+		t.Errorf("first row: got %s", got)
+	}
+	// 09:00-09:15 is the first three slots of the second row, 10:30-11:00
+	// the second half of the third
+	row := schedule.BlockInfos[1]
+	for i, blocked := range row.Blocked {
+		if want := i < 3; blocked != want {
+			t.Errorf("09:%02d: got blocked %v", i*5, blocked)
+		}
+	}
+	if p := row.Patterns[0]; p != "solid" {
+		t.Errorf("standup pattern: got %q", p)
+	}
+	row = schedule.BlockInfos[2]
+	for i, blocked := range row.Blocked {
+		if want := i >= 6; blocked != want {
+			t.Errorf("10:%02d: got blocked %v", i*5, blocked)
+		}
+	}
+	if p := row.Patterns[6]; p != "hatched" {
+		t.Errorf("review pattern: got %q", p)
+	}
+
+	schedule, err = buildSchedule(calendar, fixedClock(base.Add(30*time.Minute)), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Blocked || !schedule.FreeUntil.Equal(base.Add(90*time.Minute)) {
+		t.Errorf("free: got blocked %v until %v", schedule.Blocked, schedule.FreeUntil)
+	}
+
+	// displays wake exactly at the next change
+	schedule, err = buildSchedule(calendar, fixedClock(base.Add(65*time.Minute)), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := base.Add(90 * time.Minute)
+	if wake := wakeAt(base.Add(65*time.Minute), schedule, d); !wake.Equal(at) {
+		t.Fatalf("wake: got %v, want %v", wake, at)
+	}
+	schedule, err = buildSchedule(calendar, fixedClock(at), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schedule.Blocked || schedule.Current == nil || schedule.Current.Summary != "Review" {
+		t.Errorf("at the start: got current %+v", schedule.Current)
+	}
+	if schedule.Next != nil || !schedule.NextChange.Equal(base.Add(2*time.Hour)) {
+		t.Errorf("at the start: got next %+v, next change %v", schedule.Next, schedule.NextChange)
+	}
+}
+
+func TestWindowValidate(t *testing.T) {
+	tests := []struct {
+		window Window
+		ok     bool
+	}{
+		{Window{}, true},
+		{Window{Slot: Duration(15 * time.Minute)}, true},
+		{Window{Slot: Duration(time.Minute)}, true},
+		{Window{Slot: Duration(7 * time.Minute)}, false},
+		{Window{Slot: Duration(time.Second)}, false},
+		{Window{Slot: Duration(time.Nanosecond)}, false},
+		{Window{Start: "08:00", Hours: 25}, false},
+		{Window{Start: "08:00", End: "18:00"}, true},
+		{Window{Start: "18:00", End: "08:00"}, false},
+		{Window{Start: "08:00", End: "08:00"}, false},
+		{Window{Hours: -3}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.window.validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: got %v", tt.window, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Profile describes a panel: its resolution, the colors it can show, how it
+// is mounted and the DPI used to size text.
+type Profile struct {
+	Name     string
+	Width    int
+	Height   int
+	Palette  color.Palette
+	Rotation int
+	DPI      int
+}
+
+var green = color.RGBA{0x00, 0xff, 0x00, 0xff}
+var blue = color.RGBA{0x00, 0x00, 0xff, 0xff}
+var yellow = color.RGBA{0xff, 0xff, 0x00, 0xff}
+var orange = color.RGBA{0xff, 0x80, 0x00, 0xff}
+
+var paletteBW = color.Palette{white, black}
+var paletteBWR = color.Palette{white, black, red}
+var palette7 = color.Palette{white, black, green, blue, red, yellow, orange}
+
+var profiles = map[string]Profile{
+	"epd75b":   {Name: "epd75b", Width: 640, Height: 384, Palette: paletteBWR, DPI: 92},
+	"epd75v2":  {Name: "epd75v2", Width: 800, Height: 480, Palette: paletteBW, DPI: 92},
+	"epd75bv2": {Name: "epd75bv2", Width: 800, Height: 480, Palette: paletteBWR, DPI: 92},
+	"epd42":    {Name: "epd42", Width: 400, Height: 300, Palette: paletteBW, DPI: 92},
+	"epd565f":  {Name: "epd565f", Width: 600, Height: 448, Palette: palette7, DPI: 92},
+}
+
+var defaultProfile = profiles["epd75b"]
+
+// size returns the size of the canvas before it is rotated onto the panel.
+func (p Profile) size() (int, int) {
+	if p.Rotation%180 != 0 {
+		return p.Height, p.Width
+	}
+	return p.Width, p.Height
+}
+
+// colors snaps c to the nearest colors of the palette.
+func (p Profile) colors(c Colors) Colors {
+	snap := func(h HexColor) HexColor {
+		return HexColor(color.RGBAModel.Convert(p.Palette.Convert(h)).(color.RGBA))
+	}
+	return Colors{
+		Foreground: snap(c.Foreground),
+		Background: snap(c.Background),
+		Accent:     snap(c.Accent),
+	}
+}
+
+func validRotation(r int) error {
+	if r < 0 || r >= 360 || r%90 != 0 {
+		return fmt.Errorf("invalid rotation %d", r)
+	}
+	return nil
+}
+
+// rotate returns img rotated clockwise by degrees, a multiple of 90.
+func rotate(img *image.RGBA, degrees int) *image.RGBA {
+	if degrees%360 == 0 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var out *image.RGBA
+	if degrees%180 == 0 {
+		out = image.NewRGBA(image.Rect(0, 0, w, h))
+	} else {
+		out = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.RGBAAt(b.Min.X+x, b.Min.Y+y)
+			switch degrees % 360 {
+			case 90:
+				out.SetRGBA(h-1-y, x, c)
+			case 180:
+				out.SetRGBA(w-1-x, h-1-y, c)
+			case 270:
+				out.SetRGBA(y, w-1-x, c)
+			}
+		}
+	}
+	return out
+}
+
+// layout scales the coordinates of the original 640x384 design to a canvas.
+// Font sizes use the smaller of both factors so text keeps its proportions.
+type layout struct {
+	width, height float64
+	sx, sy, sf    float64
+}
+
+func newLayout(width, height int) layout {
+	l := layout{
+		width:  float64(width),
+		height: float64(height),
+		sx:     float64(width) / 640,
+		sy:     float64(height) / 384,
+	}
+	l.sf = math.Min(l.sx, l.sy)
+	return l
+}
+
+func (l layout) x(v float64) float64 {
+	return v * l.sx
+}
+
+func (l layout) y(v float64) float64 {
+	return v * l.sy
+}
+
+func (l layout) font(size float64) float64 {
+	return size * l.sf
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const renderUsage = `usage: paper render [flags] <display id | ics file or directory>
+
+Renders a display, as configured in $CONFIG or the environment, or the events
+of ICS files as a display of its own, without starting the server.
+
+`
+
+// parseAt reads an RFC 3339 time or a wall clock time in loc.
+func parseAt(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid at %q", s)
+}
+
+// icsDisplay is a display showing the events of the ICS file or directory at
+// path.
+func icsDisplay(path string) (Display, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Display{}, err
+	}
+	name := strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs))
+	return Display{ID: "ICS", Name: name, Feeds: []Feed{{URL: "file://" + abs}}, Timezone: "Local"}, nil
+}
+
+// runRender renders one image like /clock and writes it to -o or stdout.
+func runRender(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, renderUsage)
+		fs.PrintDefaults()
+	}
+	config := fs.String("config", os.Getenv("CONFIG"), "config file with the displays")
+	timezone := fs.String("timezone", "", "timezone of the display (default the configured one, or local for ICS files)")
+	at := fs.String("at", "", "render at this RFC 3339 time or wall clock time in the timezone (default now)")
+	name := fs.String("name", "", "name shown on the display")
+	profile := fs.String("profile", "", "panel profile (default the configured one)")
+	page := fs.Int("page", 1, "page of a floor display")
+	format := fs.String("format", "bmp", "bmp, png, pbm, gray4 or epd")
+	dither := fs.String("dither", "fs", "fs, ordered or none")
+	bitorder := fs.String("bitorder", "", "bit order of epd planes, msb or lsb")
+	pad := fs.Int("pad", 0, "pad epd rows to a multiple of this many bytes")
+	invert := fs.Bool("invert", false, "use 0 for ink in epd planes")
+	out := fs.String("o", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("render needs a display id or an ics file")
+	}
+
+	q := url.Values{"format": {*format}, "dither": {*dither}}
+	if *bitorder != "" {
+		q.Set("bitorder", *bitorder)
+	}
+	if *pad > 0 {
+		q.Set("pad", strconv.Itoa(*pad))
+	}
+	if *invert {
+		q.Set("invert", "1")
+	}
+	opts, err := parseRenderOptions(q)
+	if err != nil {
+		return err
+	}
+
+	ds, err := loadDisplays(*config)
+	if err != nil {
+		return err
+	}
+	displays.set(ds)
+	d, ok := ds[sanitize(fs.Arg(0))]
+	if !ok {
+		if _, err := os.Stat(fs.Arg(0)); err != nil {
+			return fmt.Errorf("no display or ics file %q", fs.Arg(0))
+		}
+		if d, err = icsDisplay(fs.Arg(0)); err != nil {
+			return err
+		}
+	}
+	if *timezone != "" {
+		d.Timezone = *timezone
+	}
+	if *name != "" {
+		d.Name = *name
+	}
+	if *profile != "" {
+		if _, ok := profiles[*profile]; !ok {
+			return fmt.Errorf("unknown profile %q", *profile)
+		}
+		d.Profile = *profile
+	}
+	tz, err := loadLocation(d.Timezone)
+	if err != nil {
+		return err
+	}
+
+	var c clock = systemClock{}
+	if *at != "" {
+		t, err := parseAt(*at, tz)
+		if err != nil {
+			return err
+		}
+		c = fixedClock(t)
+	}
+	calendars.clock = c
+
+	schedule, err := displaySchedule(d, c)
+	if err != nil {
+		return err
+	}
+	p := d.profile()
+	colors := p.colors(d.Colors.withDefaults())
+	_, render := renderDisplay(d, c, schedule, *page, p, colors)
+	var buf bytes.Buffer
+	if err := opts.encode(&buf, render(), p, colors); err != nil {
+		return err
+	}
+	if *out == "-" {
+		_, err = stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(*out, buf.Bytes(), 0644)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseAt(t *testing.T) {
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	want := time.Date(2026, 10, 16, 10, 50, 0, 0, zurich)
+	for _, s := range []string{"2026-10-16T10:50:00+02:00", "2026-10-16T08:50:00Z", "2026-10-16T10:50", "2026-10-16 10:50"} {
+		got, err := parseAt(s, zurich)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s: got %v, want %v", s, got, want)
+		}
+	}
+	if _, err := parseAt("tomorrow", zurich); err == nil {
+		t.Error("expected error for tomorrow")
+	}
+}
+
+func TestRunRender(t *testing.T) {
+	defer displays.set(displays.all())
+	defer func() { calendars.clock = systemClock{} }()
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ics := filepath.Join("testdata", "classify.ics")
+	args := []string{"-config", "", "-timezone", "Europe/Zurich", "-at", "2026-10-16T10:50", "-format", "png"}
+	var stdout, stderr bytes.Buffer
+	if err := runRender(append(args, ics), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := defaultProfile.size(); img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+		t.Errorf("got size %v, want %dx%d", img.Bounds().Size(), w, h)
+	}
+
+	out := filepath.Join(dir, "out.png")
+	if err := runRender(append(args, "-o", out, ics), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Error(err)
+	}
+
+	if err := runRender([]string{"-config", "", "missing"}, &stdout, &stderr); err == nil {
+		t.Error("expected error for an unknown display")
+	}
+	if err := runRender([]string{"-config", "", "-format", "gif", ics}, &stdout, &stderr); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// renderVersion changes with every build, so devices don't keep the images
+// of an older layout. It can be set with -ldflags "-X main.renderVersion=...".
+var renderVersion string
+
+var renderVersionOnce sync.Once
+
+// buildVersion returns renderVersion, by default a hash of the executable.
+func buildVersion() string {
+	renderVersionOnce.Do(func() {
+		if renderVersion != "" {
+			return
+		}
+		renderVersion = "dev"
+		path, err := os.Executable()
+		if err != nil {
+			log.Println("render version", err)
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			log.Println("render version", err)
+			return
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			log.Println("render version", err)
+			return
+		}
+		renderVersion = hex.EncodeToString(h.Sum(nil)[:8])
+	})
+	return renderVersion
+}
+
+// renderETag identifies the image rendered from these inputs by this build,
+// so it can be computed without rendering.
+func renderETag(content interface{}, profile Profile, colors Colors, opts renderOptions) (string, error) {
+	b, err := json.Marshal(struct {
+		Version string
+		Content interface{}
+		Profile Profile
+		Colors  Colors
+		Options renderOptions
+	}{buildVersion(), content, profile, colors, opts})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// etagMatches reports whether an If-None-Match header matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCache keeps the most recently rendered images by ETag.
+type renderCache struct {
+	mu      sync.Mutex
+	max     int
+	order   []string
+	entries map[string][]byte
+}
+
+func newRenderCache(max int) *renderCache {
+	return &renderCache{max: max, entries: map[string][]byte{}}
+}
+
+func (c *renderCache) get(etag string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.entries[etag]
+	return b, ok
+}
+
+// put stores b and evicts the oldest entries beyond max.
+func (c *renderCache) put(etag string, b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[etag]; ok {
+		return
+	}
+	c.entries[etag] = b
+	c.order = append(c.order, etag)
+	for len(c.order) > c.max {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
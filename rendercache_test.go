@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestServeClockETag(t *testing.T) {
+	os.Setenv("ADMIN_PASSWORD", "secret")
+	defer os.Unsetenv("ADMIN_PASSWORD")
+	defer displays.set(displays.all())
+	defer calendars.retain(nil)
+	at := time.Date(2026, 10, 16, 9, 5, 0, 0, time.UTC)
+	displays.set(map[string]Display{"ROOM": {ID: "ROOM", Name: "Room", Timezone: "UTC"}})
+	setCalendar("ROOM", at)
+
+	get := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/clock?display=room&at=2026-10-16T09:05:00Z"+query, nil)
+		r.SetBasicAuth("", "secret")
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		serveClock(w, r)
+		return w
+	}
+
+	w := get("", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || etag == "" || w.Body.Len() == 0 {
+		t.Fatalf("got status %d, etag %q and %d bytes", w.Code, etag, w.Body.Len())
+	}
+	if w = get("", etag); w.Code != 304 || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match: got status %d and %d bytes, want 304 without body", w.Code, w.Body.Len())
+	}
+	if w = get("", `"other", W/`+etag); w.Code != 304 {
+		t.Errorf("weak If-None-Match in a list: got status %d, want 304", w.Code)
+	}
+	if png := get("&format=png", "").Header().Get("ETag"); png == etag {
+		t.Error("format=png kept the etag")
+	}
+
+	setCalendar("ROOM", at, testEvent(at, at.Add(time.Hour)))
+	if w = get("", etag); w.Code != 200 || w.Header().Get("ETag") == etag {
+		t.Errorf("changed schedule: got status %d with the same etag", w.Code)
+	}
+	booked := w.Header().Get("ETag")
+
+	defer func(v string) { renderVersion = v }(buildVersion())
+	renderVersion = "next"
+	if got := get("", booked).Header().Get("ETag"); got == booked {
+		t.Error("another render version kept the etag")
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	c := newRenderCache(2)
+	c.put("a", []byte("a"))
+	c.put("b", []byte("b"))
+	c.put("a", []byte("again"))
+	c.put("c", []byte("c"))
+	if _, ok := c.get("a"); ok {
+		t.Error("oldest entry not evicted")
+	}
+	for _, etag := range []string{"b", "c"} {
+		if b, ok := c.get(etag); !ok || string(b) != etag {
+			t.Errorf("%s: got %q, %v", etag, b, ok)
+		}
+	}
+}
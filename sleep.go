@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var defaultMaxSleep = 30 * time.Minute
+var minSleep = time.Minute
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// BusinessHours is when a display is looked at. Outside of them it sleeps
+// until the next business morning.
+type BusinessHours struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
+}
+
+// parseClock returns the minutes after midnight of "15:04".
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (b BusinessHours) validate() error {
+	start, err := parseClock(b.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(b.End)
+	if err != nil {
+		return err
+	}
+	if end <= start {
+		return fmt.Errorf("business hours end %s before start %s", b.End, b.Start)
+	}
+	for _, day := range b.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+	}
+	return nil
+}
+
+func (b BusinessHours) businessDay(day time.Weekday) bool {
+	if len(b.Days) == 0 {
+		return day != time.Saturday && day != time.Sunday
+	}
+	for _, d := range b.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// on returns start and end of business hours on the day of t.
+func (b BusinessHours) on(t time.Time) (time.Time, time.Time) {
+	start, _ := parseClock(b.Start)
+	end, _ := parseClock(b.End)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return midnight.Add(time.Duration(start) * time.Minute), midnight.Add(time.Duration(end) * time.Minute)
+}
+
+func (b BusinessHours) open(t time.Time) bool {
+	start, end := b.on(t)
+	return b.businessDay(t.Weekday()) && !t.Before(start) && t.Before(end)
+}
+
+// nextOpen returns the next start of business hours after t.
+func (b BusinessHours) nextOpen(t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 12, 0, 0, 0, t.Location())
+		if !b.businessDay(day.Weekday()) {
+			continue
+		}
+		if start, _ := b.on(day); start.After(t) {
+			return start
+		}
+	}
+	return t.Add(24 * time.Hour)
+}
+
+// wakeAt returns when d should poll again: at the next change of schedule or
+// the next full hour, but no later than its max sleep. Outside of business
+// hours the display sleeps until they start.
+func wakeAt(now time.Time, schedule Schedule, d Display) time.Time {
+	loc, err := loadLocation(d.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	b := d.BusinessHours
+	if b != nil && !b.open(now) {
+		return b.nextOpen(now)
+	}
+
+	wake := time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, loc)
+	if next := schedule.NextChange; !next.IsZero() && next.Before(wake) {
+		wake = next
+	}
+	maxSleep := defaultMaxSleep
+	if d.MaxSleep > 0 {
+		maxSleep = time.Duration(d.MaxSleep)
+	}
+	if wake.After(now.Add(maxSleep)) {
+		wake = now.Add(maxSleep)
+	}
+	if b != nil {
+		if _, end := b.on(now); end.Before(wake) {
+			wake = end
+		}
+	}
+	if wake.Before(now.Add(minSleep)) {
+		wake = now.Add(minSleep)
+	}
+	return wake
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWakeAt(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, min int) time.Time {
+		// October 2026, the 16th is a Friday
+		return time.Date(2026, 10, day, hour, min, 0, 0, zurich)
+	}
+	office := &BusinessHours{Start: "07:00", End: "19:00"}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		nextChange time.Time
+		d          Display
+		want       time.Time
+	}{
+		{"next full hour", at(16, 10, 50), time.Time{}, Display{}, at(16, 11, 0)},
+		{"meeting starts", at(16, 10, 5), at(16, 10, 30), Display{}, at(16, 10, 30)},
+		{"max sleep", at(16, 10, 0), time.Time{}, Display{MaxSleep: Duration(20 * time.Minute)}, at(16, 10, 20)},
+		{"min sleep", at(16, 10, 0), at(16, 10, 0).Add(10 * time.Second), Display{}, at(16, 10, 1)},
+		{"closing", at(16, 18, 45), time.Time{}, Display{BusinessHours: office}, at(16, 19, 0)},
+		{"night", at(15, 22, 0), time.Time{}, Display{BusinessHours: office}, at(16, 7, 0)},
+		{"weekend", at(16, 19, 30), time.Time{}, Display{BusinessHours: office}, at(19, 7, 0)},
+		{"early morning", at(19, 6, 0), time.Time{}, Display{BusinessHours: office}, at(19, 7, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.Timezone = "Europe/Zurich"
+			got := wakeAt(tt.now, Schedule{NextChange: tt.nextChange}, tt.d)
+			if !got.Equal(tt.want) {
+				t.Errorf("wakeAt = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PuloV/ics-golang"
+)
+
+// source provides the events of a feed that overlap start to end.
+type source interface {
+	events(start, end time.Time) ([]ics.Event, error)
+}
+
+// overlapping returns the events that overlap start to end.
+func overlapping(events []ics.Event, start, end time.Time) []ics.Event {
+	matching := []ics.Event{}
+	for _, e := range events {
+		if e.GetStart().Before(end) && e.GetEnd().After(start) {
+			matching = append(matching, e)
+		}
+	}
+	return matching
+}
+
+var feedTypes = map[string]bool{"": true, "ics": true, "caldav": true}
+
+// icsSource downloads a whole ICS file over HTTP(S) through the fetcher.
+type icsSource struct {
+	fetcher *feedFetcher
+	feed    Feed
+}
+
+func (s icsSource) events(start, end time.Time) ([]ics.Event, error) {
+	events, err := s.fetcher.fetch(s.feed)
+	if err != nil {
+		return nil, err
+	}
+	return overlapping(events, start, end), nil
+}
+
+// fileSource reads a local ICS file, or every .ics file of a directory.
+type fileSource struct {
+	path string
+}
+
+func (s fileSource) events(start, end time.Time) ([]ics.Event, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{s.path}
+	if info.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(s.path, "*.ics")); err != nil {
+			return nil, err
+		}
+	}
+	events := []ics.Event{}
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		e, err := parseEvents(string(b), path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		events = append(events, e...)
+	}
+	return overlapping(events, start, end), nil
+}
+
+// memorySource serves fixed events, for tests and fixtures.
+type memorySource []ics.Event
+
+func (s memorySource) events(start, end time.Time) ([]ics.Event, error) {
+	return overlapping(s, start, end), nil
+}
+
+// source returns the source of feed: CalDAV by type, file:// URLs from disk
+// and everything else over HTTP(S).
+func (f *feedFetcher) source(feed Feed) source {
+	switch {
+	case feed.Type == "caldav":
+		return caldavSource{fetcher: f, feed: feed}
+	case strings.HasPrefix(feed.URL, "file://"):
+		return fileSource{path: strings.TrimPrefix(feed.URL, "file://")}
+	}
+	return icsSource{fetcher: f, feed: feed}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a.ics":    fmt.Sprintf(caldavEvent, "standup", "20261016T090000Z", "20261016T091500Z"),
+		"b.ics":    fmt.Sprintf(caldavEvent, "review", "20261016T140000Z", "20261016T150000Z"),
+		"c.ics":    fmt.Sprintf(caldavEvent, "later", "20261030T140000Z", "20261030T150000Z"),
+		"notes.md": "not a calendar",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	events, err := feeds.source(Feed{URL: "file://" + dir}).events(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("directory: got %d events, want 2", len(events))
+	}
+	events, err = feeds.source(Feed{URL: "file://" + filepath.Join(dir, "b.ics")}).events(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].GetSummary() != "review" {
+		t.Errorf("file: got %v", events)
+	}
+	if _, err := feeds.source(Feed{URL: "file://" + filepath.Join(dir, "missing.ics")}).events(start, end); err == nil {
+		t.Error("missing file: expected error")
+	}
+}
+
+func TestMemorySource(t *testing.T) {
+	base := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	s := memorySource{
+		testEvent(base, base.Add(time.Hour)).Event,
+		testEvent(base.Add(2*time.Hour), base.Add(3*time.Hour)).Event,
+	}
+	tests := []struct {
+		start, end time.Time
+		want       int
+	}{
+		{base, base.Add(3 * time.Hour), 2},
+		{base.Add(time.Hour), base.Add(2 * time.Hour), 0},
+		{base.Add(30 * time.Minute), base.Add(90 * time.Minute), 1},
+	}
+	for _, tt := range tests {
+		events, err := s.events(tt.start, tt.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != tt.want {
+			t.Errorf("%v-%v: got %d events, want %d", tt.start, tt.end, len(events), tt.want)
+		}
+	}
+}
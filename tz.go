@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuloV/ics-golang"
+)
+
+// floatingTZID marks times without a zone, which are local to the display.
+const floatingTZID = "floating"
+
+var reFloating = regexp.MustCompile(`(?m)^(DTSTART|DTEND)(;VALUE=DATE)?:(\d{8})(T\d{6})?$`)
+var reCalendarTZ = regexp.MustCompile(`(?m)^X-WR-TIMEZONE:(.*?)\s*$`)
+
+// markFloating gives floating times and dates the TZID of the calendar, or
+// floatingTZID without one. The parser reads all times as UTC and only keeps
+// the TZID, so they can be told from UTC times afterwards.
+func markFloating(content string) string {
+	tzid := floatingTZID
+	if m := reCalendarTZ.FindStringSubmatch(content); m != nil {
+		if _, err := loadLocation(m[1]); err == nil {
+			tzid = m[1]
+		}
+	}
+	return reFloating.ReplaceAllStringFunc(content, func(line string) string {
+		m := reFloating.FindStringSubmatch(line)
+		clock := m[4]
+		if clock == "" {
+			clock = "T000000"
+		}
+		return m[1] + ";TZID=" + tzid + ":" + m[3] + clock
+	})
+}
+
+// observance is the offset of a VTIMEZONE from a yearly transition on. One
+// without a rule only counts if no other has one.
+type observance struct {
+	offset  time.Duration
+	month   time.Month
+	week    int // 1 to 5, or -1 for the last
+	weekday time.Weekday
+	clock   time.Duration
+}
+
+// transition returns the wall time of the transition in year, as UTC.
+func (o observance) transition(year int) time.Time {
+	if o.week < 0 {
+		last := time.Date(year, o.month+1, 0, 0, 0, 0, 0, time.UTC)
+		day := last.AddDate(0, 0, -int((last.Weekday()-o.weekday+7)%7))
+		return day.Add(o.clock)
+	}
+	first := time.Date(year, o.month, 1, 0, 0, 0, 0, time.UTC)
+	day := first.AddDate(0, 0, int((o.weekday-first.Weekday()+7)%7)+7*(o.week-1))
+	return day.Add(o.clock)
+}
+
+// vtimezone is a zone defined in the calendar itself, such as the Windows
+// zone names of Outlook exports.
+type vtimezone []observance
+
+// offset returns the UTC offset at wall, a wall clock time read as UTC.
+func (z vtimezone) offset(wall time.Time) time.Duration {
+	var latest time.Time
+	offset := z[0].offset
+	for _, year := range []int{wall.Year() - 1, wall.Year()} {
+		for _, o := range z {
+			if o.month == 0 {
+				continue
+			}
+			if t := o.transition(year); !t.After(wall) && (latest.IsZero() || t.After(latest)) {
+				latest, offset = t, o.offset
+			}
+		}
+	}
+	return offset
+}
+
+var reVTimezone = regexp.MustCompile(`(?s)BEGIN:VTIMEZONE\n(.*?)END:VTIMEZONE`)
+var reObservance = regexp.MustCompile(`(?s)BEGIN:(?:STANDARD|DAYLIGHT)\n(.*?)END:(?:STANDARD|DAYLIGHT)`)
+var reTZID = regexp.MustCompile(`(?m)^TZID:(.*?)\s*$`)
+var reOffsetTo = regexp.MustCompile(`(?m)^TZOFFSETTO:([+-])(\d\d)(\d\d)`)
+var reObservanceStart = regexp.MustCompile(`(?m)^DTSTART:\d{8}T(\d\d)(\d\d)`)
+var reByMonth = regexp.MustCompile(`BYMONTH=(\d+)`)
+var reByDay = regexp.MustCompile(`BYDAY=(-?\d)(SU|MO|TU|WE|TH|FR|SA)`)
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// vtimezones returns the zones defined in content by TZID. Observances with
+// rules other than a weekday of a month are ignored.
+func vtimezones(content string) map[string]vtimezone {
+	zones := map[string]vtimezone{}
+	for _, block := range reVTimezone.FindAllStringSubmatch(content, -1) {
+		id := reTZID.FindStringSubmatch(block[1])
+		if id == nil {
+			continue
+		}
+		var zone vtimezone
+		for _, o := range reObservance.FindAllStringSubmatch(block[1], -1) {
+			to := reOffsetTo.FindStringSubmatch(o[1])
+			if to == nil {
+				continue
+			}
+			h, _ := strconv.Atoi(to[2])
+			m, _ := strconv.Atoi(to[3])
+			obs := observance{offset: time.Duration(h)*time.Hour + time.Duration(m)*time.Minute}
+			if to[1] == "-" {
+				obs.offset = -obs.offset
+			}
+			month, day := reByMonth.FindStringSubmatch(o[1]), reByDay.FindStringSubmatch(o[1])
+			if month != nil && day != nil {
+				mo, _ := strconv.Atoi(month[1])
+				obs.month = time.Month(mo)
+				obs.week, _ = strconv.Atoi(day[1])
+				obs.weekday = icsWeekdays[day[2]]
+				if start := reObservanceStart.FindStringSubmatch(o[1]); start != nil {
+					h, _ := strconv.Atoi(start[1])
+					m, _ := strconv.Atoi(start[2])
+					obs.clock = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+				}
+			} else if strings.Contains(o[1], "RRULE") {
+				continue
+			}
+			zone = append(zone, obs)
+		}
+		if len(zone) > 0 {
+			zones[id[1]] = zone
+		}
+	}
+	return zones
+}
+
+type location struct {
+	loc *time.Location
+	err error
+}
+
+var locations sync.Map
+
+// loadLocation is time.LoadLocation, remembering the zones it loaded or
+// failed to load.
+func loadLocation(name string) (*time.Location, error) {
+	if l, ok := locations.Load(name); ok {
+		return l.(location).loc, l.(location).err
+	}
+	loc, err := time.LoadLocation(name)
+	locations.Store(name, location{loc, err})
+	return loc, err
+}
+
+// unknownZones are the TZIDs already logged as unknown.
+var unknownZones sync.Map
+
+// inZone returns the instant of wall, a wall clock time read as UTC, in loc.
+func inZone(wall time.Time, loc *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+}
+
+// resolveTZID returns the instant of wall in the zone tzid. UTC and floating
+// times are returned unchanged.
+func resolveTZID(wall time.Time, tzid string, zones map[string]vtimezone) time.Time {
+	tzid = strings.Trim(tzid, `"`)
+	if tzid == "" || tzid == floatingTZID {
+		return wall
+	}
+	if loc, err := loadLocation(tzid); err == nil {
+		return inZone(wall, loc)
+	}
+	if zone, ok := zones[tzid]; ok {
+		return wall.Add(-zone.offset(wall))
+	}
+	if _, logged := unknownZones.LoadOrStore(tzid, true); !logged {
+		log.Printf("unknown timezone %q, using UTC", tzid)
+	}
+	return wall
+}
+
+// resolveTimes turns the start and end of events into instants by their
+// TZID, except floating ones.
+func resolveTimes(events []ics.Event, zones map[string]vtimezone) {
+	for i := range events {
+		e := &events[i]
+		e.SetStart(resolveTZID(e.GetStart(), e.GetStartTZID(), zones))
+		e.SetEnd(resolveTZID(e.GetEnd(), e.GetEndTZID(), zones))
+	}
+}
+
+// resolveFloating returns e with floating times in loc.
+func resolveFloating(e ics.Event, loc *time.Location) ics.Event {
+	if e.GetStartTZID() == floatingTZID {
+		e.SetStart(inZone(e.GetStart(), loc))
+	}
+	if e.GetEndTZID() == floatingTZID {
+		e.SetEnd(inZone(e.GetEnd(), loc))
+	}
+	return e
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const windowsZone = `BEGIN:VTIMEZONE
+TZID:W. Europe Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+`
+
+// dstCalendar returns a calendar with one event and an optional VTIMEZONE.
+func dstCalendar(zone, start, end string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + zone +
+		"BEGIN:VEVENT\r\nUID:dst\r\nDTSTAMP:20260101T000000Z\r\n" +
+		start + "\r\n" + end + "\r\nSUMMARY:Meeting\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+}
+
+// blockedSlots returns the number of blocked slots of every row.
+func blockedSlots(s Schedule) []int {
+	var blocked []int
+	for _, info := range s.BlockInfos {
+		n := 0
+		for _, b := range info.Blocked {
+			if b {
+				n++
+			}
+		}
+		blocked = append(blocked, n)
+	}
+	return blocked
+}
+
+func TestDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		calendar string
+		now      string
+		rows     []string
+		blocked  []int
+	}{
+		{
+			"zurich spring forward", "Europe/Zurich",
+			dstCalendar("", "DTSTART;TZID=Europe/Zurich:20260329T030000", "DTEND;TZID=Europe/Zurich:20260329T040000"),
+			"2026-03-29T00:30:00+01:00",
+			[]string{"00:00", "01:00", "03:00", "04:00", "05:00"},
+			[]int{0, 0, 12, 0, 0},
+		},
+		{
+			"zurich fall back", "Europe/Zurich",
+			dstCalendar("", "DTSTART;TZID=Europe/Zurich:20261025T030000", "DTEND;TZID=Europe/Zurich:20261025T040000"),
+			"2026-10-25T00:30:00+02:00",
+			[]string{"00:00", "01:00", "02:00", "02:00", "03:00"},
+			[]int{0, 0, 0, 0, 12},
+		},
+		{
+			"new york spring forward", "America/New_York",
+			dstCalendar("", "DTSTART;TZID=America/New_York:20260308T030000", "DTEND;TZID=America/New_York:20260308T033000"),
+			"2026-03-08T00:30:00-05:00",
+			[]string{"00:00", "01:00", "03:00", "04:00", "05:00"},
+			[]int{0, 0, 6, 0, 0},
+		},
+		{
+			"new york fall back", "America/New_York",
+			dstCalendar("", "DTSTART;TZID=America/New_York:20261101T020000", "DTEND;TZID=America/New_York:20261101T030000"),
+			"2026-11-01T00:30:00-04:00",
+			[]string{"00:00", "01:00", "01:00", "02:00", "03:00"},
+			[]int{0, 0, 0, 12, 0},
+		},
+		{
+			"sydney fall back", "Australia/Sydney",
+			dstCalendar("", "DTSTART;TZID=Australia/Sydney:20260405T030000", "DTEND;TZID=Australia/Sydney:20260405T040000"),
+			"2026-04-05T00:30:00+11:00",
+			[]string{"00:00", "01:00", "02:00", "02:00", "03:00"},
+			[]int{0, 0, 0, 0, 12},
+		},
+		{
+			"floating time", "Europe/Zurich",
+			dstCalendar("", "DTSTART:20261025T030000", "DTEND:20261025T040000"),
+			"2026-10-25T00:30:00+02:00",
+			[]string{"00:00", "01:00", "02:00", "02:00", "03:00"},
+			[]int{0, 0, 0, 0, 12},
+		},
+		{
+			"utc time", "Europe/Zurich",
+			dstCalendar("", "DTSTART:20261025T020000Z", "DTEND:20261025T030000Z"),
+			"2026-10-25T00:30:00+02:00",
+			[]string{"00:00", "01:00", "02:00", "02:00", "03:00"},
+			[]int{0, 0, 0, 0, 12},
+		},
+		{
+			"vtimezone fall back", "Europe/Zurich",
+			dstCalendar(windowsZone, "DTSTART;TZID=W. Europe Standard Time:20261025T030000", "DTEND;TZID=W. Europe Standard Time:20261025T040000"),
+			"2026-10-25T00:30:00+02:00",
+			[]string{"00:00", "01:00", "02:00", "02:00", "03:00"},
+			[]int{0, 0, 0, 0, 12},
+		},
+		{
+			"vtimezone summer", "Europe/Zurich",
+			dstCalendar(windowsZone, "DTSTART;TZID=W. Europe Standard Time:20260701T020000", "DTEND;TZID=W. Europe Standard Time:20260701T030000"),
+			"2026-07-01T00:30:00+02:00",
+			[]string{"00:00", "01:00", "02:00", "03:00", "04:00"},
+			[]int{0, 0, 12, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		now, err := time.Parse(time.RFC3339, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := Display{Timezone: tt.timezone, Window: Window{Start: "00:00", Hours: 5}}
+		events, err := parseEvents(tt.calendar, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schedule, err := buildSchedule(d.calendarEvents(events, ""), fixedClock(now), d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []string
+		for _, info := range schedule.BlockInfos {
+			rows = append(rows, info.Time)
+		}
+		if !reflect.DeepEqual(rows, tt.rows) {
+			t.Errorf("%s: got rows %v, want %v", tt.name, rows, tt.rows)
+		}
+		if got := blockedSlots(schedule); !reflect.DeepEqual(got, tt.blocked) {
+			t.Errorf("%s: got blocked %v, want %v", tt.name, got, tt.blocked)
+		}
+		if schedule.Next == nil || !schedule.Next.Start.Equal(schedule.Start.Add(time.Duration(firstBlocked(tt.blocked))*time.Hour)) {
+			t.Errorf("%s: got next %+v", tt.name, schedule.Next)
+		}
+	}
+}
+
+func firstBlocked(blocked []int) int {
+	for i, n := range blocked {
+		if n > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestResolveUnknownTZID(t *testing.T) {
+	wall := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if got := resolveTZID(wall, "Mars Standard Time", nil); !got.Equal(wall) {
+			t.Errorf("got %v, want %v as UTC", got, wall)
+		}
+	}
+	if _, err := loadLocation("Mars Standard Time"); err == nil {
+		t.Error("expected error for an unknown zone")
+	}
+	if _, logged := unknownZones.Load("Mars Standard Time"); !logged {
+		t.Error("unknown zone not remembered")
+	}
+}